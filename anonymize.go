@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	lair "github.com/lair-framework/go-lair"
+)
+
+// loadAnonymizeKey reads the secret used to derive placeholders from
+// filename.
+func loadAnonymizeKey(filename string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimSpace(buf)
+	if len(key) == 0 {
+		return nil, errors.New("the key file is empty")
+	}
+	return key, nil
+}
+
+// pseudonym returns a stable placeholder for value so that the same person
+// maps to the same placeholder across runs with the same key. The placeholder
+// is an HMAC rather than a plain hash, since names and email local parts are
+// easily recovered from a plain hash with a dictionary.
+func pseudonym(key []byte, prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return prefix + "-" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// anonymizePerson replaces the names, emails and phone numbers of per with
+// placeholders keyed with key. The domain of each email is kept so people can still
+// be grouped by organization. The free text fields that can identify the
+// person on their own, such as the address and job title, are dropped.
+func anonymizePerson(per *lair.Person, key []byte) {
+	per.FirstName = pseudonym(key, "first", per.FirstName)
+	per.MiddleName = ""
+	per.LastName = pseudonym(key, "last", per.LastName)
+	per.DisplayName = ""
+	per.Address = ""
+	per.Department = ""
+	per.Description = ""
+	per.PrincipalName = anonymizeEmail(per.PrincipalName, key)
+	for i, email := range per.Emails {
+		per.Emails[i] = anonymizeEmail(email, key)
+	}
+	for i, phone := range per.Phones {
+		per.Phones[i] = pseudonym(key, "phone", phone)
+	}
+}
+
+// anonymizeEmail replaces the local part of email with a placeholder.
+func anonymizeEmail(email string, key []byte) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return pseudonym(key, "user", email)
+	}
+	return pseudonym(key, "user", email[:at]) + email[at:]
+}
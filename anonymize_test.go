@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	lair "github.com/lair-framework/go-lair"
+)
+
+func TestAnonymizePerson(t *testing.T) {
+	key := []byte("secret")
+	per := lair.Person{
+		FirstName:     "Alice",
+		MiddleName:    "B",
+		LastName:      "Smith",
+		PrincipalName: "alice@example.com",
+		Emails:        []string{"alice@example.com"},
+		Phones:        []string{"+1 555 0100"},
+		DisplayName:   "Alice Smith",
+		Address:       "Springfield",
+		Department:    "Head of Security",
+		Description:   "Registrant contact for netblocks 10.0.0.0/24",
+	}
+	again := per
+	again.Emails = []string{"Alice@example.com"}
+	again.Phones = []string{"+1 555 0100"}
+	anonymizePerson(&per, key)
+	anonymizePerson(&again, key)
+
+	if per.FirstName == "Alice" || per.LastName == "Smith" || per.MiddleName != "" || per.DisplayName != "" {
+		t.Errorf("names were not anonymized: %+v", per)
+	}
+	if per.Address != "" || per.Department != "" || per.Description != "" {
+		t.Errorf("address, department and description were kept: %+v", per)
+	}
+	if !strings.HasSuffix(per.Emails[0], "@example.com") || strings.HasPrefix(per.Emails[0], "alice") {
+		t.Errorf("email = %q, want a placeholder at example.com", per.Emails[0])
+	}
+	if per.Emails[0] != again.Emails[0] || per.FirstName != again.FirstName || per.Phones[0] != again.Phones[0] {
+		t.Errorf("placeholders differ for the same person with the same key: %+v, %+v", per, again)
+	}
+}
+
+func TestPseudonymIsKeyed(t *testing.T) {
+	a := pseudonym([]byte("one"), "first", "alice")
+	b := pseudonym([]byte("two"), "first", "alice")
+	if a == b {
+		t.Errorf("pseudonym is the same for different keys: %q", a)
+	}
+	// A dictionary of plain hashes must not reveal the value.
+	sum := sha256.Sum256([]byte("alice"))
+	if strings.Contains(a, hex.EncodeToString(sum[:])[:12]) {
+		t.Errorf("pseudonym %q is an unkeyed hash of the value", a)
+	}
+	if got := pseudonym([]byte("one"), "first", ""); got != "" {
+		t.Errorf("pseudonym of an empty value = %q, want empty", got)
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/lair-framework/api-server/client"
+	lair "github.com/lair-framework/go-lair"
+)
+
+// apiClient is the subset of the lair API used by the drone. It is satisfied
+// by transportClient and by interruptibleClient.
+type apiClient interface {
+	ExportProject(id string) (lair.Project, error)
+	ImportProject(opts *client.DOptions, project *lair.Project) (*http.Response, error)
+	ProjectIDByName(name string) (string, error)
+}
+
+// interruptContext returns a context that is canceled when the process
+// receives SIGINT or SIGTERM.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		// A second signal kills the process if shutting down hangs.
+		signal.Stop(sigs)
+		log.Printf("Info: Received %s, shutting down\n", sig)
+		cancel()
+	}()
+	return ctx
+}
+
+// interruptibleClient wraps an apiClient so that calls return
+// context.Canceled as soon as ctx is canceled, instead of waiting for the
+// request to complete. The request itself is abandoned when the process
+// exits.
+type interruptibleClient struct {
+	ctx context.Context
+	c   apiClient
+}
+
+// ExportProject implements apiClient.
+func (i *interruptibleClient) ExportProject(id string) (lair.Project, error) {
+	type result struct {
+		project lair.Project
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		project, err := i.c.ExportProject(id)
+		done <- result{project, err}
+	}()
+	select {
+	case r := <-done:
+		return r.project, r.err
+	case <-i.ctx.Done():
+		return lair.Project{}, i.ctx.Err()
+	}
+}
+
+// ImportProject implements apiClient.
+func (i *interruptibleClient) ImportProject(opts *client.DOptions, project *lair.Project) (*http.Response, error) {
+	type result struct {
+		res *http.Response
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := i.c.ImportProject(opts, project)
+		done <- result{res, err}
+	}()
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-i.ctx.Done():
+		return nil, i.ctx.Err()
+	}
+}
+
+// ProjectIDByName implements apiClient.
+func (i *interruptibleClient) ProjectIDByName(name string) (string, error) {
+	type result struct {
+		id  string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		id, err := i.c.ProjectIDByName(name)
+		done <- result{id, err}
+	}()
+	select {
+	case r := <-done:
+		return r.id, r.err
+	case <-i.ctx.Done():
+		return "", i.ctx.Err()
+	}
+}
+
+// authError is returned by importProject and transportClient when the API
+// server rejects the client's credentials.
+type authError struct {
+	code   int
+	status string
+}
+
+func (e *authError) Error() string {
+	return fmt.Sprintf("API server returned %s", e.status)
+}
+
+// statusError is returned by transportClient when the API server responds
+// with an unexpected status code.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("non 200 status code returned from the API server: %s", e.status)
+}
+
+// errorStatus returns the HTTP status code that caused err, if any. Only
+// authError and statusError carry one, so transport errors, whose messages
+// may hold the request URL with the project id and port, never do.
+func errorStatus(err error) (int, bool) {
+	switch e := err.(type) {
+	case *authError:
+		return e.code, true
+	case *statusError:
+		return e.code, true
+	}
+	return 0, false
+}
+
+// importProject imports project using c and checks the server's response for
+// errors. The server may accept an import while dropping some of the data,
+// for example ports removed by data protection, in which case the details are
+// in the returned message.
+func importProject(c apiClient, opts *client.DOptions, project *lair.Project) (string, error) {
+	res, err := c.ImportProject(opts, project)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return "", &authError{code: res.StatusCode, status: res.Status}
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	droneRes := &client.Response{}
+	if err := json.Unmarshal(body, droneRes); err != nil {
+		return "", fmt.Errorf("could not unmarshal JSON response: %s", err.Error())
+	}
+	if droneRes.Status == "Error" {
+		return "", fmt.Errorf("import failed: %s", droneRes.Message)
+	}
+	return strings.TrimSpace(droneRes.Message), nil
+}
+
+// batchProject splits project into projects of at most size hosts each. Every
+// batch carries the project's id, tool and commands, while the notes,
+// netblocks, people and credentials are only sent with the first batch. A size of zero
+// or less returns project unchanged.
+func batchProject(project *lair.Project, size int) []*lair.Project {
+	if size <= 0 || len(project.Hosts) <= size {
+		return []*lair.Project{project}
+	}
+	batches := []*lair.Project{}
+	for start := 0; start < len(project.Hosts); start += size {
+		end := start + size
+		if end > len(project.Hosts) {
+			end = len(project.Hosts)
+		}
+		batch := &lair.Project{
+			ID:       project.ID,
+			Tool:     project.Tool,
+			Commands: project.Commands,
+			Hosts:    project.Hosts[start:end],
+		}
+		if start == 0 {
+			batch.Notes = project.Notes
+			batch.Netblocks = project.Netblocks
+			batch.People = project.People
+			batch.Credentials = project.Credentials
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// projectClass is the part of a project holding a single class of data.
+type projectClass struct {
+	name    string
+	project *lair.Project
+}
+
+// splitClasses splits project into one project per non-empty class of data.
+// The hosts carry the project's commands and notes, so they are only sent
+// once.
+func splitClasses(project *lair.Project) []projectClass {
+	classes := []projectClass{}
+	base := func() *lair.Project {
+		return &lair.Project{ID: project.ID, Tool: project.Tool}
+	}
+	hosts := base()
+	hosts.Commands = project.Commands
+	hosts.Notes = project.Notes
+	hosts.Hosts = project.Hosts
+	classes = append(classes, projectClass{"hosts", hosts})
+	if len(project.Netblocks) > 0 {
+		p := base()
+		p.Netblocks = project.Netblocks
+		classes = append(classes, projectClass{"netblocks", p})
+	}
+	if len(project.People) > 0 {
+		p := base()
+		p.People = project.People
+		classes = append(classes, projectClass{"people", p})
+	}
+	if len(project.Credentials) > 0 {
+		p := base()
+		p.Credentials = project.Credentials
+		classes = append(classes, projectClass{"credentials", p})
+	}
+	return classes
+}
+
+// transportClient talks to the lair API server using a caller supplied
+// http.Client, so that every request carries the drone's TLS, proxy, tunnel
+// and redirect settings. client.C builds its own transport internally and
+// only reports status codes in its error messages, so it is not used.
+type transportClient struct {
+	user     string
+	password string
+	host     string
+	scheme   string
+	hc       *http.Client
+	// stream encodes imported projects directly into the request body
+	// rather than marshaling them into a buffer first.
+	stream bool
+}
+
+// newTransportClient returns a transportClient for the API server at u.
+func newTransportClient(user, password string, u *url.URL, hc *http.Client) *transportClient {
+	return &transportClient{
+		user:     user,
+		password: password,
+		host:     u.Host,
+		scheme:   u.Scheme,
+		hc:       hc,
+	}
+}
+
+// newClient returns the apiClient for the API server at u, using the TLS,
+// proxy, tunnel and redirect settings in o. Calls return early when ctx is
+// canceled. The returned tunnel, if any, must be closed by the caller.
+func newClient(ctx context.Context, o *options, u *url.URL, user, pass string) (apiClient, *sshTunnel) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: o.insecureSSL}
+	if o.caCert != "" {
+		pem, err := ioutil.ReadFile(o.caCert)
+		if err != nil {
+			fatalf(exitFileIO, "Fatal: Could not read CA certificate. Error %s\n", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatalf("Fatal: No PEM encoded certificates found in %s\n", o.caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if o.clientCert != "" || o.clientKey != "" {
+		if o.clientCert == "" || o.clientKey == "" {
+			log.Fatal("Fatal: -client-cert and -client-key must be used together")
+		}
+		cert, err := tls.LoadX509KeyPair(o.clientCert, o.clientKey)
+		if err != nil {
+			log.Fatalf("Fatal: Could not load client certificate. Error %s\n", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	var tunnel *sshTunnel
+	if o.sshTunnelTarget != "" {
+		var err error
+		tunnel, err = dialSSHTunnel(o.sshTunnelTarget, o.sshKey, o.sshKnownHosts)
+		if err != nil {
+			log.Fatalf("Fatal: Could not establish SSH tunnel. Error %s\n", err.Error())
+		}
+		transport.Proxy = nil
+		transport.DialContext = tunnel.DialContext
+	}
+	if o.skipVerifyHost != "" {
+		if o.insecureSSL {
+			log.Println("Warning: -k disables verification for all hosts, -skip-verify-host has no effect")
+		} else {
+			// Connections through an HTTP proxy do not use DialTLSContext and
+			// are always verified.
+			transport.DialTLSContext = newSkipVerifyDialer(o.skipVerifyHost, tlsConfig, transport.DialContext).DialTLSContext
+			log.Printf("Warning: TLS certificate verification is disabled for %s\n", o.skipVerifyHost)
+		}
+	}
+	hc := &http.Client{Transport: transport}
+	if o.noFollowRedirects {
+		// A redirect to a plain HTTP endpoint would leak the credentials, so
+		// the redirect response is returned as is.
+		hc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	tc := newTransportClient(user, pass, u, hc)
+	tc.stream = o.streamImport
+	return &interruptibleClient{ctx: ctx, c: tc}, tunnel
+}
+
+func (t *transportClient) url(id string, query url.Values) string {
+	u := &url.URL{
+		Scheme:   t.scheme,
+		Host:     t.host,
+		Path:     fmt.Sprintf("/api/projects/%s", id),
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// ExportProject exports the project with the given id.
+func (t *transportClient) ExportProject(id string) (lair.Project, error) {
+	project := lair.Project{}
+	req, err := http.NewRequest("GET", t.url(id, nil), nil)
+	if err != nil {
+		return project, err
+	}
+	req.SetBasicAuth(t.user, t.password)
+	res, err := t.hc.Do(req)
+	if err != nil {
+		return project, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return project, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return project, &authError{code: res.StatusCode, status: res.Status}
+	default:
+		return project, &statusError{code: res.StatusCode, status: res.Status}
+	}
+	err = json.Unmarshal(body, &project)
+	return project, err
+}
+
+// ProjectIDByName returns the id of the only project named name, compared
+// case-insensitively, in the list of projects from the API server.
+func (t *transportClient) ProjectIDByName(name string) (string, error) {
+	u := &url.URL{Scheme: t.scheme, Host: t.host, Path: "/api/projects"}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(t.user, t.password)
+	res, err := t.hc.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", &authError{code: res.StatusCode, status: res.Status}
+	default:
+		return "", &statusError{code: res.StatusCode, status: res.Status}
+	}
+	projects := []lair.Project{}
+	if err := json.Unmarshal(body, &projects); err != nil {
+		return "", err
+	}
+	ids := []string{}
+	for _, p := range projects {
+		if strings.EqualFold(strings.TrimSpace(p.Name), strings.TrimSpace(name)) {
+			ids = append(ids, p.ID)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no project named %q", name)
+	case 1:
+		return ids[0], nil
+	}
+	return "", fmt.Errorf("%d projects are named %q: %s", len(ids), name, strings.Join(ids, ", "))
+}
+
+// ImportProject imports project into lair. The caller is responsible for
+// closing the body of the returned response.
+func (t *transportClient) ImportProject(opts *client.DOptions, project *lair.Project) (*http.Response, error) {
+	if project.ID == "" {
+		return nil, fmt.Errorf("missing required project id")
+	}
+	var body io.Reader
+	if t.stream {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(json.NewEncoder(pw).Encode(project))
+		}()
+		body = pr
+	} else {
+		buf, err := json.Marshal(project)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(buf)
+	}
+	query := url.Values{}
+	if opts.ForcePorts {
+		query.Set("force-ports", "true")
+	}
+	if opts.LimitHosts {
+		query.Set("limit-hosts", "true")
+	}
+	req, err := http.NewRequest("PATCH", t.url(project.ID, query), body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(t.user, t.password)
+	req.Header.Set("Content-Type", "application/json")
+	return t.hc.Do(req)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/lair-framework/api-server/client"
+	lair "github.com/lair-framework/go-lair"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		auth        bool
+		notFound    bool
+		wantCode    int
+		wantHasCode bool
+	}{
+		{
+			name: "connection refused with status-like digits in the URL",
+			err:  errors.New(`Get "https://lair:11401/api/projects/5a4031fe4030404f": dial tcp 10.0.0.1:11401: connect: connection refused`),
+		},
+		{
+			name: "EOF from a server on port 4040",
+			err:  errors.New(`Get "https://lair:4040/api/projects/abc": EOF`),
+		},
+		{
+			name:        "authError",
+			err:         &authError{code: http.StatusForbidden, status: "403 Forbidden"},
+			auth:        true,
+			wantCode:    403,
+			wantHasCode: true,
+		},
+		{
+			name:        "statusError",
+			err:         &statusError{code: http.StatusNotFound, status: "404 Not Found"},
+			notFound:    true,
+			wantCode:    404,
+			wantHasCode: true,
+		},
+		{
+			name:        "statusError for a server error",
+			err:         &statusError{code: http.StatusInternalServerError, status: "500 Internal Server Error"},
+			wantCode:    500,
+			wantHasCode: true,
+		},
+	}
+	for _, tt := range tests {
+		code, ok := errorStatus(tt.err)
+		if code != tt.wantCode || ok != tt.wantHasCode {
+			t.Errorf("%s: errorStatus() = %d, %v, want %d, %v", tt.name, code, ok, tt.wantCode, tt.wantHasCode)
+		}
+		if got := isAuthError(tt.err); got != tt.auth {
+			t.Errorf("%s: isAuthError() = %v, want %v", tt.name, got, tt.auth)
+		}
+		if got := isNotFoundError(tt.err); got != tt.notFound {
+			t.Errorf("%s: isNotFoundError() = %v, want %v", tt.name, got, tt.notFound)
+		}
+	}
+}
+
+func benchmarkImportProject(b *testing.B, stream bool) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		w.Write([]byte(`{"Status": "Ok", "Message": ""}`))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	project := &lair.Project{ID: "pid"}
+	for i := 0; i < 10000; i++ {
+		project.Hosts = append(project.Hosts, lair.Host{
+			IPv4:      fmt.Sprintf("10.%d.%d.%d", i>>16, (i>>8)&255, i&255),
+			Hostnames: []string{fmt.Sprintf("host%d.example.com", i)},
+			Tags:      []string{"recon"},
+		})
+	}
+	c := newTransportClient("user", "pass", u, srv.Client())
+	c.stream = stream
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res, err := c.ImportProject(&client.DOptions{}, project)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+	}
+}
+
+func BenchmarkImportProjectBuffered(b *testing.B) { benchmarkImportProject(b, false) }
+
+func BenchmarkImportProjectStreamed(b *testing.B) { benchmarkImportProject(b, true) }
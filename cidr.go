@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"sort"
+	"strings"
+
+	lair "github.com/lair-framework/go-lair"
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+// splitCIDRHosts separates the recon-ng hosts whose ip_address holds a CIDR
+// rather than a single address. When expand is true each such host is
+// replaced by one host per lair host in the range, so that it is reconciled
+// like any other result, and its extra columns are kept under the new
+// address in extra. Otherwise the CIDRs are returned as netblocks.
+func splitCIDRHosts(results []reconng.Host, hosts []lair.Host, expand bool, extra *extraData) ([]reconng.Host, []reconng.NetBlock) {
+	kept := []reconng.Host{}
+	netblocks := []reconng.NetBlock{}
+	for _, r := range results {
+		if !strings.Contains(r.IPAddress, "/") {
+			kept = append(kept, r)
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(r.IPAddress)
+		if err != nil {
+			kept = append(kept, r)
+			continue
+		}
+		if !expand {
+			netblocks = append(netblocks, reconng.NetBlock{Netblock: ipnet.String()})
+			continue
+		}
+		for _, h := range hosts {
+			if ip := net.ParseIP(h.IPv4); ip != nil && ipnet.Contains(ip) {
+				expanded := r
+				expanded.IPAddress = h.IPv4
+				extra.rekey(r, expanded)
+				kept = append(kept, expanded)
+			}
+		}
+	}
+	return kept, netblocks
+}
+
+// normalizeCIDR returns cidr in its canonical form, so that equivalent
+// spellings such as 10.0.0.0/08 and 10.1.2.3/8 compare equal. Values that do
+// not parse are returned trimmed and lowercased.
+func normalizeCIDR(cidr string) string {
+	_, ipnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(cidr))
+	}
+	return ipnet.String()
+}
+
+// normalizeMAC returns mac in the lowercase colon separated form, so that
+// the different notations accepted by net.ParseMAC compare equal.
+func normalizeMAC(mac string) (string, bool) {
+	hw, err := net.ParseMAC(strings.TrimSpace(mac))
+	if err != nil {
+		return "", false
+	}
+	return hw.String(), true
+}
+
+// lessIP orders IP addresses numerically, with values that are not IPs
+// ordered after all IPs and compared as strings.
+func lessIP(a, b string) bool {
+	ipa, ipb := net.ParseIP(a), net.ParseIP(b)
+	switch {
+	case ipa != nil && ipb != nil:
+		return bytes.Compare(ipa.To16(), ipb.To16()) < 0
+	case ipa != nil:
+		return true
+	case ipb != nil:
+		return false
+	}
+	return a < b
+}
+
+// containingNetblock returns the most specific of cidrs that contains ip.
+func containingNetblock(ip string, cidrs []string) (string, bool) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", false
+	}
+	best, bestOnes := "", -1
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil || !ipnet.Contains(addr) {
+			continue
+		}
+		if ones, _ := ipnet.Mask.Size(); ones > bestOnes {
+			best, bestOnes = ipnet.String(), ones
+		}
+	}
+	return best, bestOnes != -1
+}
+
+// ipv4ToLong returns the IPv4 address ip as an integer, the form lair stores
+// in LongIPv4Addr. Octets with leading zeros are read as decimal.
+func ipv4ToLong(ip string) (uint64, bool) {
+	// Parsed by hand since it is called for every recon-ng result.
+	ip = strings.TrimSpace(ip)
+	var long, octet uint64
+	octets, digits := 0, 0
+	for i := 0; i <= len(ip); i++ {
+		if i < len(ip) && ip[i] >= '0' && ip[i] <= '9' {
+			octet = octet*10 + uint64(ip[i]-'0')
+			digits++
+			if octet > 255 {
+				return 0, false
+			}
+			continue
+		}
+		if digits == 0 || (i < len(ip) && ip[i] != '.') {
+			return 0, false
+		}
+		long = long<<8 | octet
+		octet, digits = 0, 0
+		octets++
+	}
+	if octets != 4 {
+		return 0, false
+	}
+	return long, true
+}
+
+// hostIndex maps addresses to the indexes of the lair hosts that have them.
+type hostIndex struct {
+	byIP   map[string][]int
+	byLong map[uint64][]int
+}
+
+// newHostIndex indexes hosts by IP. When byLong is set IPv4 addresses are also
+// indexed by their integer form, from LongIPv4Addr when lair has set it.
+func newHostIndex(hosts []lair.Host, byLong bool) *hostIndex {
+	x := &hostIndex{byIP: map[string][]int{}}
+	if byLong {
+		x.byLong = map[uint64][]int{}
+	}
+	for i, h := range hosts {
+		x.byIP[h.IPv4] = append(x.byIP[h.IPv4], i)
+		if x.byLong == nil {
+			continue
+		}
+		long, ok := h.LongIPv4Addr, h.LongIPv4Addr != 0
+		if !ok {
+			long, ok = ipv4ToLong(h.IPv4)
+		}
+		if ok {
+			x.byLong[long] = append(x.byLong[long], i)
+		}
+	}
+	return x
+}
+
+// lookup returns the indexes of the hosts with the address ip.
+func (x *hostIndex) lookup(ip string) []int {
+	if x.byLong != nil {
+		if long, ok := ipv4ToLong(ip); ok {
+			return x.byLong[long]
+		}
+	}
+	return x.byIP[ip]
+}
+
+// cidrContains reports whether the network outer contains the network inner.
+// Equal networks contain each other.
+func cidrContains(outer, inner string) bool {
+	_, o, err := net.ParseCIDR(strings.TrimSpace(outer))
+	if err != nil {
+		return false
+	}
+	_, i, err := net.ParseCIDR(strings.TrimSpace(inner))
+	if err != nil {
+		return false
+	}
+	oOnes, oBits := o.Mask.Size()
+	iOnes, iBits := i.Mask.Size()
+	return oBits == iBits && oOnes <= iOnes && o.Contains(i.IP)
+}
+
+// prefixLength returns the prefix length of cidr, or a length longer than
+// any prefix if it does not parse.
+func prefixLength(cidr string) int {
+	_, ipnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return 129
+	}
+	ones, _ := ipnet.Mask.Size()
+	return ones
+}
+
+// dedupNetblocks drops each netblock in netblocks that is equal to or within
+// a netblock in existing, or within a larger netblock in netblocks. The
+// metadata of a dropped netblock is merged onto the netblock containing it,
+// and the existing netblocks that gain metadata are returned along with the
+// netblocks that are kept. A netblock that contains an existing netblock is
+// kept, since an import cannot remove the smaller one from lair.
+func dedupNetblocks(existing, netblocks []lair.Netblock) []lair.Netblock {
+	existing = append([]lair.Netblock{}, existing...)
+	merged := make([]bool, len(existing))
+	// Larger netblocks are considered first so that the netblocks within
+	// them are the ones dropped.
+	order := make([]int, len(netblocks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return prefixLength(netblocks[order[a]].CIDR) < prefixLength(netblocks[order[b]].CIDR)
+	})
+	kept := make([]bool, len(netblocks))
+	keptOrder := []int{}
+candidates:
+	for _, i := range order {
+		n := netblocks[i]
+		for j := range existing {
+			if cidrContains(existing[j].CIDR, n.CIDR) {
+				log.Printf("Info: Skipping netblock %s, it is within existing netblock %s\n", n.CIDR, existing[j].CIDR)
+				merged[j] = mergeNetblock(&existing[j], n) || merged[j]
+				continue candidates
+			}
+		}
+		for _, k := range keptOrder {
+			if cidrContains(netblocks[k].CIDR, n.CIDR) {
+				log.Printf("Info: Skipping netblock %s, it is within netblock %s\n", n.CIDR, netblocks[k].CIDR)
+				mergeNetblock(&netblocks[k], n)
+				continue candidates
+			}
+		}
+		for _, e := range existing {
+			if cidrContains(n.CIDR, e.CIDR) {
+				log.Printf("Warning: Netblock %s contains existing netblock %s, remove %s in lair to avoid the overlap\n", n.CIDR, e.CIDR, e.CIDR)
+			}
+		}
+		kept[i] = true
+		keptOrder = append(keptOrder, i)
+	}
+	result := []lair.Netblock{}
+	for i, n := range netblocks {
+		if kept[i] {
+			result = append(result, n)
+		}
+	}
+	for j, n := range existing {
+		if merged[j] {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// mergeNetblock fills in the fields of dst that are empty from src and
+// reports whether dst changed.
+func mergeNetblock(dst *lair.Netblock, src lair.Netblock) bool {
+	changed := false
+	fill := func(d *string, s string) {
+		if *d == "" && s != "" {
+			*d = s
+			changed = true
+		}
+	}
+	fill(&dst.Handle, src.Handle)
+	fill(&dst.MiscEmails, src.MiscEmails)
+	fill(&dst.Description, src.Description)
+	return changed
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	lair "github.com/lair-framework/go-lair"
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+func TestHostIndexMatchesStringPath(t *testing.T) {
+	hosts := []lair.Host{
+		{IPv4: "10.0.0.1", LongIPv4Addr: 167772161},
+		{IPv4: "10.0.0.2"},
+		{IPv4: "192.168.1.10"},
+		{IPv4: "10.0.0.1"},
+		{IPv4: "fe80::1"},
+	}
+	byString := newHostIndex(hosts, false)
+	byLong := newHostIndex(hosts, true)
+	// Canonical addresses match the same hosts either way.
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "192.168.1.10", "fe80::1", "10.9.9.9"} {
+		if got, want := byLong.lookup(ip), byString.lookup(ip); !reflect.DeepEqual(got, want) {
+			t.Errorf("lookup(%q) = %v by long, %v by string", ip, got, want)
+		}
+	}
+	// Differently formatted addresses only match by long.
+	if got := byString.lookup("10.0.0.01"); len(got) != 0 {
+		t.Errorf("string lookup(10.0.0.01) = %v, want no match", got)
+	}
+	if got, want := byLong.lookup("10.0.0.01"), []int{0, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("long lookup(10.0.0.01) = %v, want %v", got, want)
+	}
+	if got, want := byLong.lookup("010.000.000.002"), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("long lookup(010.000.000.002) = %v, want %v", got, want)
+	}
+}
+
+func TestSplitCIDRHosts(t *testing.T) {
+	results := []reconng.Host{
+		{IPAddress: "10.0.0.0/30", Name: "net.example.com"},
+		{IPAddress: "10.0.0.9", Name: "single.example.com"},
+	}
+	hosts := []lair.Host{{IPv4: "10.0.0.1"}, {IPv4: "10.0.0.2"}, {IPv4: "10.0.0.4"}}
+
+	kept, netblocks := splitCIDRHosts(results, hosts, true, nil)
+	want := []reconng.Host{
+		{IPAddress: "10.0.0.1", Name: "net.example.com"},
+		{IPAddress: "10.0.0.2", Name: "net.example.com"},
+		{IPAddress: "10.0.0.9", Name: "single.example.com"},
+	}
+	if !reflect.DeepEqual(kept, want) || len(netblocks) != 0 {
+		t.Errorf("expanded = %v, %v, want %v and no netblocks", kept, netblocks, want)
+	}
+
+	kept, netblocks = splitCIDRHosts(results, hosts, false, nil)
+	if len(kept) != 1 || kept[0].IPAddress != "10.0.0.9" {
+		t.Errorf("kept = %v, want only 10.0.0.9", kept)
+	}
+	if len(netblocks) != 1 || netblocks[0].Netblock != "10.0.0.0/30" {
+		t.Errorf("netblocks = %v, want 10.0.0.0/30", netblocks)
+	}
+}
+
+func TestContainingNetblock(t *testing.T) {
+	cidrs := []string{"10.0.0.0/8", "10.1.0.0/16", "bogus", "192.168.0.0/24"}
+	tests := []struct {
+		ip   string
+		want string
+		ok   bool
+	}{
+		{"10.1.2.3", "10.1.0.0/16", true},
+		{"10.2.0.1", "10.0.0.0/8", true},
+		{"172.16.0.1", "", false},
+		{"not an ip", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := containingNetblock(tt.ip, cidrs); got != tt.want || ok != tt.ok {
+			t.Errorf("containingNetblock(%q) = %q, %v, want %q, %v", tt.ip, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	for _, mac := range []string{"00:1a:2b:3c:4d:5e", "00-1A-2B-3C-4D-5E", " 001a.2b3c.4d5e "} {
+		if got, ok := normalizeMAC(mac); !ok || got != "00:1a:2b:3c:4d:5e" {
+			t.Errorf("normalizeMAC(%q) = %q, %v, want 00:1a:2b:3c:4d:5e", mac, got, ok)
+		}
+	}
+	if got, ok := normalizeMAC("not a mac"); ok {
+		t.Errorf("normalizeMAC(not a mac) = %q, want false", got)
+	}
+}
+
+func TestCIDRContains(t *testing.T) {
+	tests := []struct {
+		outer, inner string
+		want         bool
+	}{
+		{"10.0.0.0/16", "10.0.5.0/24", true},
+		{"10.0.0.0/16", "10.0.0.0/16", true},
+		{"10.0.5.0/24", "10.0.0.0/16", false},
+		{"10.0.0.0/16", "10.1.0.0/24", false},
+		{"10.0.0.0/16", "bogus", false},
+		{"::/0", "10.0.0.0/8", false},
+	}
+	for _, tt := range tests {
+		if got := cidrContains(tt.outer, tt.inner); got != tt.want {
+			t.Errorf("cidrContains(%q, %q) = %v, want %v", tt.outer, tt.inner, got, tt.want)
+		}
+	}
+}
+
+func TestDedupNetblocks(t *testing.T) {
+	existing := []lair.Netblock{{ID: "n1", CIDR: "10.0.0.0/16"}}
+
+	// A /24 inside an existing /16 is dropped and its metadata merged onto
+	// the /16.
+	got := dedupNetblocks(existing, []lair.Netblock{{CIDR: "10.0.5.0/24", Description: "ACME"}})
+	if len(got) != 1 || got[0].ID != "n1" || got[0].Description != "ACME" {
+		t.Errorf("/24 within /16 = %+v, want n1 with the description ACME", got)
+	}
+	if existing[0].Description != "" {
+		t.Errorf("existing netblock was modified: %+v", existing[0])
+	}
+
+	// An equal CIDR without new metadata is dropped entirely.
+	if got := dedupNetblocks(existing, []lair.Netblock{{CIDR: "10.0.0.0/16"}}); len(got) != 0 {
+		t.Errorf("equal CIDR = %+v, want nothing sent", got)
+	}
+
+	// Within the import the larger netblock is kept.
+	got = dedupNetblocks(nil, []lair.Netblock{
+		{CIDR: "192.168.1.0/24", Handle: "NET-1"},
+		{CIDR: "192.168.0.0/16"},
+		{CIDR: "172.16.0.0/12"},
+	})
+	want := []lair.Netblock{{CIDR: "192.168.0.0/16", Handle: "NET-1"}, {CIDR: "172.16.0.0/12"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("overlapping imports = %+v, want %+v", got, want)
+	}
+}
+
+func TestIPv4ToLong(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want uint64
+		ok   bool
+	}{
+		{"10.0.0.1", 167772161, true},
+		{" 10.0.0.01 ", 167772161, true},
+		{"255.255.255.255", 4294967295, true},
+		{"10.0.0.256", 0, false},
+		{"10.0.0", 0, false},
+		{"fe80::1", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ipv4ToLong(tt.ip)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ipv4ToLong(%q) = %d, %v, want %d, %v", tt.ip, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func benchmarkHostIndex(b *testing.B, byLong bool) {
+	hosts := make([]lair.Host, 65536)
+	ips := make([]string, len(hosts))
+	for i := range hosts {
+		hosts[i].IPv4 = fmt.Sprintf("10.%d.%d.%d", i>>16, (i>>8)&255, i&255)
+		ips[i] = hosts[i].IPv4
+	}
+	x := newHostIndex(hosts, byLong)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.lookup(ips[i%len(ips)])
+	}
+}
+
+func BenchmarkHostIndexString(b *testing.B) { benchmarkHostIndex(b, false) }
+
+func BenchmarkHostIndexLong(b *testing.B) { benchmarkHostIndex(b, true) }
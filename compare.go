@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	lair "github.com/lair-framework/go-lair"
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+// changeset returns the changes importing project would make to exproject,
+// which must be the project as exported before reconciliation. Each change is
+// a single line of space separated fields, beginning with the kind of change,
+// and the lines are sorted so that the output is stable.
+func changeset(exproject *lair.Project, project *lair.Project, notFound map[string][]reconng.Host, forced bool) []string {
+	existing := map[string]lair.Host{}
+	for _, h := range exproject.Hosts {
+		existing[h.IPv4] = h
+	}
+	changes := []string{}
+	for _, h := range project.Hosts {
+		before, ok := existing[h.IPv4]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("host-new %s %s", h.IPv4, strings.Join(h.Hostnames, ",")))
+			continue
+		}
+		if added := appendHostnames(append([]string{}, before.Hostnames...), h.Hostnames...)[len(before.Hostnames):]; len(added) > 0 {
+			changes = append(changes, fmt.Sprintf("host-hostnames %s %s", h.IPv4, strings.Join(added, ",")))
+		}
+		if added := appendServices(append([]lair.Service{}, before.Services...), h.Services...)[len(before.Services):]; len(added) > 0 {
+			ports := []string{}
+			for _, s := range added {
+				ports = append(ports, fmt.Sprintf("%d/%s", s.Port, s.Protocol))
+			}
+			changes = append(changes, fmt.Sprintf("host-services %s %s", h.IPv4, strings.Join(ports, ",")))
+		}
+	}
+	if !forced {
+		for ip, results := range notFound {
+			changes = append(changes, fmt.Sprintf("host-notfound %s %s", ip, strings.Join(resultHostnames(results), ",")))
+		}
+	}
+	cidrs := map[string]bool{}
+	for _, n := range exproject.Netblocks {
+		cidrs[normalizeCIDR(n.CIDR)] = true
+	}
+	for _, n := range project.Netblocks {
+		if !cidrs[normalizeCIDR(n.CIDR)] {
+			changes = append(changes, fmt.Sprintf("netblock-new %s", n.CIDR))
+		}
+	}
+	emails := map[string]bool{}
+	for _, p := range exproject.People {
+		for _, e := range p.Emails {
+			emails[strings.ToLower(e)] = true
+		}
+	}
+	for _, p := range project.People {
+		known := false
+		for _, e := range p.Emails {
+			known = known || emails[strings.ToLower(e)]
+		}
+		if !known {
+			changes = append(changes, fmt.Sprintf("person-new %s", p.PrincipalName))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// newHostnames maps the IP of every host in project that gains hostnames over
+// exproject, the project as exported before reconciliation, to the hostnames
+// it gains. All hostnames of hosts that are not in exproject are new.
+func newHostnames(exproject *lair.Project, project *lair.Project) map[string][]string {
+	existing := map[string][]string{}
+	for _, h := range exproject.Hosts {
+		existing[h.IPv4] = h.Hostnames
+	}
+	added := map[string][]string{}
+	for _, h := range project.Hosts {
+		before := existing[h.IPv4]
+		if names := appendHostnames(append([]string{}, before...), h.Hostnames...)[len(before):]; len(names) > 0 {
+			added[h.IPv4] = names
+		}
+	}
+	return added
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// columnCoverage is the number of rows of a table that hold a column and
+// whether the drone maps it.
+type columnCoverage struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Rows   int    `json:"rows"`
+	Mapped bool   `json:"mapped"`
+}
+
+// fieldCoverage reports every column seen in the parsed recon-ng tables,
+// sorted by table and column. The columns in importedColumns and the columns
+// used to filter rows are mapped, hostColumns are the extra host columns
+// recorded as notes, and the tables the drone does not import are mapped only
+// when passthrough is set.
+func fieldCoverage(extra *extraData, hostColumns []string, passthrough bool) []columnCoverage {
+	mapped := map[string]map[string]bool{}
+	for table, columns := range importedColumns {
+		mapped[table] = map[string]bool{}
+		for _, column := range columns {
+			mapped[table][column] = true
+		}
+	}
+	for _, column := range hostColumns {
+		mapped["hosts"][column] = true
+	}
+	filters := append([]string{"confidence"}, deletedColumns...)
+
+	coverage := []columnCoverage{}
+	for table, columns := range extra.columns {
+		for column, rows := range columns {
+			ok := mapped[table][column]
+			for _, f := range filters {
+				ok = ok || column == f
+			}
+			if !knownTables[table] {
+				ok = passthrough
+			}
+			coverage = append(coverage, columnCoverage{Table: table, Column: column, Rows: rows, Mapped: ok})
+		}
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		if coverage[i].Table != coverage[j].Table {
+			return coverage[i].Table < coverage[j].Table
+		}
+		return coverage[i].Column < coverage[j].Column
+	})
+	return coverage
+}
+
+// writeCoverage writes coverage to w as an aligned table, or as JSON when
+// asJSON is set.
+func writeCoverage(w io.Writer, coverage []columnCoverage, asJSON bool) error {
+	if asJSON {
+		buf, err := json.MarshalIndent(coverage, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(buf))
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "TABLE\tCOLUMN\tROWS\tSTATUS\n")
+	for _, c := range coverage {
+		status := "ignored"
+		if c.Mapped {
+			status = "mapped"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", c.Table, c.Column, c.Rows, status)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// importCache records the inputs imported recently so that an identical
+// import can be skipped. Each import is stored as an empty file named after
+// its key, with the import time as its modification time.
+type importCache struct {
+	dir string
+}
+
+// defaultCacheDir returns the directory the import cache is stored in when
+// -dedup-cache-dir is not given.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "drone-recon-ng")
+}
+
+// importKey returns a key identifying the import of filenames into project.
+// The key changes whenever the content of any file changes.
+func importKey(project string, filenames []string) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(project))
+	for _, filename := range filenames {
+		buf, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(buf)
+		h.Write(sum[:])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// seen returns the time key was last imported if that was within window.
+func (c *importCache) seen(key string, window time.Duration) (time.Time, bool) {
+	fi, err := os.Stat(filepath.Join(c.dir, key))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), time.Since(fi.ModTime()) < window
+}
+
+// record marks key as imported now.
+func (c *importCache) record(key string) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	filename := filepath.Join(c.dir, key)
+	if err := ioutil.WriteFile(filename, nil, 0600); err != nil {
+		return err
+	}
+	now := time.Now()
+	return os.Chtimes(filename, now, now)
+}
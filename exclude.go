@@ -0,0 +1,211 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+// hostFilter matches hosts by IP, CIDR range or hostname.
+type hostFilter struct {
+	ips   map[string]bool
+	nets  []*net.IPNet
+	names map[string]bool
+}
+
+// loadHostFilter builds a hostFilter from value, which is either a file with
+// one entry per line or a comma separated list of entries. Lines starting with
+// # are ignored.
+func loadHostFilter(value string) (*hostFilter, error) {
+	entries := strings.Split(value, ",")
+	if fi, err := os.Stat(value); err == nil && !fi.IsDir() {
+		buf, err := ioutil.ReadFile(value)
+		if err != nil {
+			return nil, err
+		}
+		entries = strings.Split(string(buf), "\n")
+	}
+	f := &hostFilter{ips: map[string]bool{}, names: map[string]bool{}}
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		switch {
+		case e == "" || strings.HasPrefix(e, "#"):
+		case strings.Contains(e, "/"):
+			_, ipnet, err := net.ParseCIDR(e)
+			if err != nil {
+				return nil, err
+			}
+			f.nets = append(f.nets, ipnet)
+		case net.ParseIP(e) != nil:
+			f.ips[net.ParseIP(e).String()] = true
+		default:
+			f.names[strings.ToLower(strings.TrimSuffix(e, "."))] = true
+		}
+	}
+	return f, nil
+}
+
+// match reports whether ip or hostname is in the filter.
+func (f *hostFilter) match(ip, hostname string) bool {
+	if hostname != "" && f.names[strings.ToLower(strings.TrimSuffix(hostname, "."))] {
+		return true
+	}
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return false
+	}
+	if f.ips[parsed.String()] {
+		return true
+	}
+	for _, n := range f.nets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeHosts removes the recon-ng hosts and ports matched by f and returns
+// the number of host rows that were removed. A port is removed when its IP or
+// host is matched.
+func excludeHosts(data *reconng.Data, extra *extraData, f *hostFilter) int {
+	hosts := []reconng.Host{}
+	for _, result := range data.Hosts {
+		if !f.match(result.IPAddress, result.Name) {
+			hosts = append(hosts, result)
+		}
+	}
+	dropped := len(data.Hosts) - len(hosts)
+	data.Hosts = hosts
+
+	ports := []reconPort{}
+	for _, p := range extra.Ports {
+		if !f.match(string(p.IPAddress), string(p.Host)) {
+			ports = append(ports, p)
+		}
+	}
+	extra.Ports = ports
+	return dropped
+}
+
+// ipVersionMatch reports whether ip belongs to the address family version,
+// which is "4", "6" or "both". A CIDR belongs to the family of its network,
+// and values that are neither only match "both".
+func ipVersionMatch(ip, version string) bool {
+	if version == "both" {
+		return true
+	}
+	ip = strings.TrimSpace(ip)
+	parsed := net.ParseIP(ip)
+	if _, ipnet, err := net.ParseCIDR(ip); err == nil {
+		parsed = ipnet.IP
+	}
+	if parsed == nil {
+		return false
+	}
+	if parsed.To4() != nil {
+		return version == "4"
+	}
+	return version == "6"
+}
+
+// filterIPVersion removes the recon-ng hosts and ports outside the address
+// family version and returns the number of host rows that were removed.
+func filterIPVersion(data *reconng.Data, extra *extraData, version string) int {
+	hosts := []reconng.Host{}
+	for _, result := range data.Hosts {
+		if ipVersionMatch(result.IPAddress, version) {
+			hosts = append(hosts, result)
+		}
+	}
+	dropped := len(data.Hosts) - len(hosts)
+	data.Hosts = hosts
+
+	ports := []reconPort{}
+	for _, p := range extra.Ports {
+		if ipVersionMatch(string(p.IPAddress), version) {
+			ports = append(ports, p)
+		}
+	}
+	extra.Ports = ports
+	return dropped
+}
+
+// loadScopeDomains reads the parent domains listed one per line in filename.
+// Lines starting with # are ignored.
+func loadScopeDomains(filename string) ([]string, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	domains := []string{}
+	for _, line := range strings.Split(string(buf), "\n") {
+		line = strings.ToLower(strings.Trim(strings.TrimSpace(line), "."))
+		if line != "" && !strings.HasPrefix(line, "#") {
+			domains = append(domains, line)
+		}
+	}
+	return domains, nil
+}
+
+// inScope reports whether hostname is one of domains or a subdomain of one.
+func inScope(hostname string, domains []string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(hostname), "."))
+	for _, d := range domains {
+		if hostname == d || strings.HasSuffix(hostname, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeHosts removes the recon-ng hosts and ports whose hostname is not in
+// domains and returns the number of host rows that were removed. Rows without
+// a hostname are kept.
+func scopeHosts(data *reconng.Data, extra *extraData, domains []string) int {
+	hosts := []reconng.Host{}
+	for _, result := range data.Hosts {
+		if result.Name == "" || inScope(result.Name, domains) {
+			hosts = append(hosts, result)
+		}
+	}
+	dropped := len(data.Hosts) - len(hosts)
+	data.Hosts = hosts
+
+	ports := []reconPort{}
+	for _, p := range extra.Ports {
+		if p.Host == "" || inScope(string(p.Host), domains) {
+			ports = append(ports, p)
+		}
+	}
+	extra.Ports = ports
+	return dropped
+}
+
+// netblockHosts removes the recon-ng hosts and ports whose IP address is not
+// in any of cidrs and returns the addresses of the host rows that were
+// removed.
+func netblockHosts(data *reconng.Data, extra *extraData, cidrs []string) []string {
+	hosts := []reconng.Host{}
+	dropped := []string{}
+	for _, result := range data.Hosts {
+		if _, ok := containingNetblock(result.IPAddress, cidrs); ok {
+			hosts = append(hosts, result)
+			continue
+		}
+		dropped = append(dropped, result.IPAddress)
+	}
+	data.Hosts = hosts
+
+	ports := []reconPort{}
+	for _, p := range extra.Ports {
+		if _, ok := containingNetblock(string(p.IPAddress), cidrs); ok {
+			ports = append(ports, p)
+		}
+	}
+	extra.Ports = ports
+	return dropped
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+func TestNetblockHosts(t *testing.T) {
+	data := &reconng.Data{Hosts: []reconng.Host{
+		{IPAddress: "10.0.0.5", Name: "in.example.com"},
+		{IPAddress: "192.168.1.5", Name: "out.example.com"},
+	}}
+	extra := &extraData{Ports: []reconPort{
+		{IPAddress: "10.0.0.5", Port: "443"},
+		{IPAddress: "192.168.1.5", Port: "80"},
+	}}
+	dropped := netblockHosts(data, extra, []string{"10.0.0.0/24"})
+	if want := []string{"192.168.1.5"}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("dropped = %v, want %v", dropped, want)
+	}
+	if len(data.Hosts) != 1 || data.Hosts[0].IPAddress != "10.0.0.5" {
+		t.Errorf("hosts = %v, want only 10.0.0.5", data.Hosts)
+	}
+	if len(extra.Ports) != 1 || extra.Ports[0].IPAddress != "10.0.0.5" {
+		t.Errorf("ports = %v, want only 10.0.0.5", extra.Ports)
+	}
+}
+
+// Hosts with a CIDR address are split off as netblocks before the netblock
+// scope is applied, so they are neither dropped nor logged.
+func TestNetblockHostsAfterCIDRSplit(t *testing.T) {
+	data := &reconng.Data{Hosts: []reconng.Host{
+		{IPAddress: "172.16.0.0/16"},
+		{IPAddress: "172.16.4.4"},
+	}}
+	var netblocks []reconng.NetBlock
+	data.Hosts, netblocks = splitCIDRHosts(data.Hosts, nil, false, nil)
+	data.NetBlocks = append(data.NetBlocks, netblocks...)
+	if len(data.NetBlocks) != 1 || data.NetBlocks[0].Netblock != "172.16.0.0/16" {
+		t.Fatalf("netblocks = %v, want 172.16.0.0/16", data.NetBlocks)
+	}
+	dropped := netblockHosts(data, &extraData{}, []string{data.NetBlocks[0].Netblock})
+	if len(dropped) != 0 {
+		t.Errorf("dropped = %v, want none", dropped)
+	}
+	if len(data.Hosts) != 1 || data.Hosts[0].IPAddress != "172.16.4.4" {
+		t.Errorf("hosts = %v, want only 172.16.4.4", data.Hosts)
+	}
+}
+
+func TestIPVersionMatch(t *testing.T) {
+	for _, tc := range []struct {
+		ip, version string
+		want        bool
+	}{
+		{"10.0.0.1", "4", true},
+		{"10.0.0.1", "6", false},
+		{"2001:db8::1", "6", true},
+		{"10.0.0.0/24", "4", true},
+		{"10.0.0.0/24", "6", false},
+		{"2001:db8::/32", "4", false},
+		{"not-an-ip", "4", false},
+		{"not-an-ip", "both", true},
+	} {
+		if got := ipVersionMatch(tc.ip, tc.version); got != tc.want {
+			t.Errorf("ipVersionMatch(%q, %q) = %v, want %v", tc.ip, tc.version, got, tc.want)
+		}
+	}
+}
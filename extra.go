@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lair "github.com/lair-framework/go-lair"
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+// extraData holds the recon-ng tables that the recon-ng parser does not
+// expose. It is decoded from the same JSON document passed to reconng.Parse.
+type extraData struct {
+	Workspace flexString     `json:"workspace"`
+	Hosts     []reconHost    `json:"hosts"`
+	Ports     []reconPort    `json:"ports"`
+	Contacts  []reconContact `json:"contacts"`
+
+	// workspaces is the set of distinct workspace names seen across all of
+	// the merged files.
+	workspaces []string
+
+	// hostIndex caches the rows in Hosts keyed by hostKey.
+	hostIndex map[string][]reconHost
+	// aliases maps the hostKey of a host that was rewritten after parsing,
+	// such as one whose hostname was discarded, to the hostKeys of the rows
+	// it was derived from.
+	aliases map[string][]string
+
+	// unknown holds the rows of every table that the drone does not import,
+	// keyed by table name.
+	unknown map[string][]json.RawMessage
+
+	// columns counts the rows holding each column, keyed by table name and
+	// then column name.
+	columns map[string]map[string]int
+}
+
+// knownTables are the recon-ng tables the drone imports.
+var knownTables = map[string]bool{
+	"hosts":       true,
+	"contacts":    true,
+	"credentials": true,
+	"netblocks":   true,
+	"ports":       true,
+}
+
+// reconHost holds the columns of a recon-ng hosts row that the recon-ng
+// parser does not expose, along with the columns needed to identify the row.
+type reconHost struct {
+	IPAddress flexString `json:"ip_address"`
+	Host      flexString `json:"host"`
+	Timestamp flexString `json:"timestamp"`
+	Status    flexString `json:"status"`
+	// OS, OSConfidence and Module are set by modules that fingerprint hosts.
+	OS           flexString `json:"os"`
+	OSConfidence flexString `json:"os_confidence"`
+	Module       flexString `json:"module"`
+	// MAC is set by modules that discover hosts on the local network.
+	MAC flexString `json:"mac"`
+
+	// workspace is the workspace of the export the row was read from.
+	workspace string
+	// columns holds every column of the row.
+	columns map[string]flexString
+}
+
+// reconPort is a row from the recon-ng ports table.
+type reconPort struct {
+	IPAddress flexString `json:"ip_address"`
+	Host      flexString `json:"host"`
+	Port      flexString `json:"port"`
+	Protocol  flexString `json:"protocol"`
+	Timestamp flexString `json:"timestamp"`
+}
+
+// timestampLayouts are the layouts accepted for recon-ng timestamp columns.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// parseTimestamp parses a recon-ng timestamp column, reporting false if the
+// value is empty or not a recognized timestamp.
+func parseTimestamp(v flexString) (time.Time, bool) {
+	s := strings.TrimSpace(string(v))
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// hostKey returns the key used to match a reconHost with the corresponding
+// reconng.Host.
+func hostKey(ip, name string) string {
+	return ip + "|" + strings.ToLower(name)
+}
+
+// hostRows returns the rows in the hosts table for the host.
+func (e *extraData) hostRows(r reconng.Host) []reconHost {
+	if e.hostIndex == nil {
+		e.hostIndex = map[string][]reconHost{}
+		for _, h := range e.Hosts {
+			// Hosts with multiple IPs are split before reconciliation, so
+			// the row is indexed under each IP.
+			for _, ip := range strings.Split(string(h.IPAddress), ",") {
+				key := hostKey(strings.TrimSpace(ip), string(h.Host))
+				e.hostIndex[key] = append(e.hostIndex[key], h)
+			}
+		}
+	}
+	key := hostKey(r.IPAddress, r.Name)
+	rows := e.hostIndex[key]
+	for _, alias := range e.aliases[key] {
+		rows = append(rows[:len(rows):len(rows)], e.hostIndex[alias]...)
+	}
+	return rows
+}
+
+// rekey records that the host from was rewritten as to, so that the rows of
+// from are still found for to.
+func (e *extraData) rekey(from, to reconng.Host) {
+	fromKey, toKey := hostKey(from.IPAddress, from.Name), hostKey(to.IPAddress, to.Name)
+	if e == nil || fromKey == toKey {
+		return
+	}
+	if e.aliases == nil {
+		e.aliases = map[string][]string{}
+	}
+	for _, key := range append([]string{fromKey}, e.aliases[fromKey]...) {
+		found := false
+		for _, alias := range e.aliases[toKey] {
+			found = found || alias == key
+		}
+		if !found {
+			e.aliases[toKey] = append(e.aliases[toKey], key)
+		}
+	}
+}
+
+// hostTimestamp returns the latest discovery time recorded for the host.
+func (e *extraData) hostTimestamp(r reconng.Host) (time.Time, bool) {
+	latest := time.Time{}
+	found := false
+	for _, h := range e.hostRows(r) {
+		if t, ok := parseTimestamp(h.Timestamp); ok && (!found || t.After(latest)) {
+			latest = t
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// hostStatus returns the status recorded for the host, if any.
+func (e *extraData) hostStatus(r reconng.Host) string {
+	for _, h := range e.hostRows(r) {
+		if status := strings.TrimSpace(string(h.Status)); status != "" {
+			return status
+		}
+	}
+	return ""
+}
+
+// hostOS returns the OS guess recorded for the host with the highest
+// confidence, if any.
+func (e *extraData) hostOS(r reconng.Host) (lair.OS, string, bool) {
+	best := lair.OS{}
+	module := ""
+	found := false
+	for _, h := range e.hostRows(r) {
+		fingerprint := strings.TrimSpace(string(h.OS))
+		if fingerprint == "" {
+			continue
+		}
+		weight, _ := strconv.Atoi(strings.TrimSpace(string(h.OSConfidence)))
+		if !found || weight > best.Weight {
+			best = lair.OS{Tool: tool, Weight: weight, Fingerprint: fingerprint}
+			module = strings.TrimSpace(string(h.Module))
+			found = true
+		}
+	}
+	return best, module, found
+}
+
+// hostWorkspaces returns the workspaces the host was exported from.
+func (e *extraData) hostWorkspaces(r reconng.Host) []string {
+	workspaces := []string{}
+	for _, h := range e.hostRows(r) {
+		if h.workspace != "" {
+			workspaces = append(workspaces, h.workspace)
+		}
+	}
+	return workspaces
+}
+
+// hostMAC returns the normalized MAC address recorded for the host, if any.
+func (e *extraData) hostMAC(r reconng.Host) (string, bool) {
+	for _, h := range e.hostRows(r) {
+		if mac, ok := normalizeMAC(string(h.MAC)); ok {
+			return mac, true
+		}
+	}
+	return "", false
+}
+
+// hostColumn returns the distinct non-empty values of column across the rows
+// of the host.
+func (e *extraData) hostColumn(r reconng.Host, column string) []string {
+	values := []string{}
+	seen := map[string]bool{}
+	for _, h := range e.hostRows(r) {
+		v := strings.TrimSpace(string(h.columns[column]))
+		if v != "" && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// latestTimestamp returns the most recent discovery time across all hosts and
+// ports.
+func (e *extraData) latestTimestamp() time.Time {
+	latest := time.Time{}
+	for _, h := range e.Hosts {
+		if t, ok := parseTimestamp(h.Timestamp); ok && t.After(latest) {
+			latest = t
+		}
+	}
+	for _, p := range e.Ports {
+		if t, ok := parseTimestamp(p.Timestamp); ok && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// reconContact holds the columns of a recon-ng contacts row that the recon-ng
+// parser does not expose, along with the columns needed to identify the row.
+type reconContact struct {
+	FirstName flexString `json:"first_name"`
+	LastName  flexString `json:"last_name"`
+	Email     flexString `json:"email"`
+	Phone     flexString `json:"phone"`
+}
+
+// contactKey returns the key used to match a reconContact with the
+// corresponding reconng.Contact.
+func contactKey(email, firstName, lastName string) string {
+	return strings.ToLower(strings.Join([]string{email, firstName, lastName}, "|"))
+}
+
+// phone returns the normalized phone number recorded for the contact, or an
+// empty string if there is none.
+func (e *extraData) phone(c reconng.Contact) string {
+	key := contactKey(c.Email, c.FirstName, c.LastName)
+	for _, rc := range e.Contacts {
+		if contactKey(string(rc.Email), string(rc.FirstName), string(rc.LastName)) == key {
+			if p := normalizePhone(string(rc.Phone)); p != "" {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// normalizePhone collapses whitespace in a phone number and drops characters
+// that do not belong in one. Values without any digits are discarded.
+func normalizePhone(phone string) string {
+	digits := 0
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+			return r
+		case strings.ContainsRune("+-().x ", r):
+			return r
+		case r == '\t':
+			return ' '
+		}
+		return -1
+	}, phone)
+	if digits == 0 {
+		return ""
+	}
+	return strings.Join(strings.Fields(cleaned), " ")
+}
+
+// flexString is a recon-ng column value. Recon-ng does not enforce column
+// types, so a value may be encoded as a string, a number or null.
+type flexString string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *flexString) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*f = ""
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*f = flexString(s)
+		return nil
+	}
+	*f = flexString(b)
+	return nil
+}
+
+// parseExtra decodes the tables in buf that are not handled by reconng.Parse.
+func parseExtra(buf []byte) (*extraData, error) {
+	extra := &extraData{}
+	if err := json.Unmarshal(buf, extra); err != nil {
+		return nil, err
+	}
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	if raw, ok := doc["hosts"]; ok {
+		rows := []map[string]flexString{}
+		if err := json.Unmarshal(raw, &rows); err == nil && len(rows) == len(extra.Hosts) {
+			for i := range rows {
+				extra.Hosts[i].columns = rows[i]
+			}
+		}
+	}
+	extra.unknown = map[string][]json.RawMessage{}
+	extra.columns = map[string]map[string]int{}
+	for table, raw := range doc {
+		tableRows := []map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &tableRows); err == nil {
+			extra.columns[table] = map[string]int{}
+			for _, row := range tableRows {
+				for column := range row {
+					extra.columns[table][column]++
+				}
+			}
+		}
+		if knownTables[table] {
+			continue
+		}
+		rows := []json.RawMessage{}
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			// Not a table.
+			continue
+		}
+		extra.unknown[table] = rows
+	}
+	return extra, nil
+}
+
+// mergeExtra appends all of the records in src to dst.
+func mergeExtra(dst, src *extraData) {
+	for i := range src.Hosts {
+		src.Hosts[i].workspace = strings.TrimSpace(string(src.Workspace))
+	}
+	dst.Hosts = append(dst.Hosts, src.Hosts...)
+	dst.Ports = append(dst.Ports, src.Ports...)
+	dst.hostIndex = nil
+	dst.Contacts = append(dst.Contacts, src.Contacts...)
+	if dst.unknown == nil {
+		dst.unknown = map[string][]json.RawMessage{}
+	}
+	for table, rows := range src.unknown {
+		dst.unknown[table] = append(dst.unknown[table], rows...)
+	}
+	if dst.columns == nil {
+		dst.columns = map[string]map[string]int{}
+	}
+	for table, columns := range src.columns {
+		if dst.columns[table] == nil {
+			dst.columns[table] = map[string]int{}
+		}
+		for column, rows := range columns {
+			dst.columns[table][column] += rows
+		}
+	}
+	if w := strings.TrimSpace(string(src.Workspace)); w != "" {
+		found := false
+		for _, existing := range dst.workspaces {
+			if existing == w {
+				found = true
+			}
+		}
+		if !found {
+			dst.workspaces = append(dst.workspaces, w)
+		}
+	}
+}
+
+// passthroughNotes returns a project note for every row of every table the
+// drone does not import, so that no recon-ng data is lost. The notes are
+// sorted by table name.
+func (e *extraData) passthroughNotes() ([]lair.Note, []string) {
+	tables := []string{}
+	for table := range e.unknown {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	notes := []lair.Note{}
+	for _, table := range tables {
+		for _, row := range e.unknown[table] {
+			buf := &bytes.Buffer{}
+			if err := json.Compact(buf, row); err != nil {
+				buf.Reset()
+				buf.Write(row)
+			}
+			notes = append(notes, lair.Note{
+				Title:          "recon-ng " + table,
+				Content:        buf.String(),
+				LastModifiedBy: tool,
+			})
+		}
+	}
+	return notes, tables
+}
+
+// service converts the port to a lair service.
+func (p reconPort) service() (lair.Service, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(string(p.Port)))
+	if err != nil || port < 1 || port > 65535 {
+		return lair.Service{}, fmt.Errorf("invalid port %q", p.Port)
+	}
+	protocol := strings.ToLower(strings.TrimSpace(string(p.Protocol)))
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	return lair.Service{
+		Port:           port,
+		Protocol:       protocol,
+		Service:        "unknown",
+		LastModifiedBy: tool,
+	}, nil
+}
+
+// appendServices appends each service in add to services unless a service
+// with the same port and protocol is already present.
+func appendServices(services []lair.Service, add ...lair.Service) []lair.Service {
+	for _, a := range add {
+		found := false
+		for _, s := range services {
+			if s.Port == a.Port && strings.EqualFold(s.Protocol, a.Protocol) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			services = append(services, a)
+		}
+	}
+	return services
+}
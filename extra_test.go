@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	lair "github.com/lair-framework/go-lair"
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+func TestExtraColumnsSurviveRewrites(t *testing.T) {
+	long := "a-very-long-label.example.com"
+	data := &reconng.Data{Hosts: []reconng.Host{
+		{IPAddress: "10.0.0.1", Name: long},
+		{IPAddress: "10.0.0.0/30", Name: "net.example.com"},
+	}}
+	extra := &extraData{Hosts: []reconHost{
+		{IPAddress: "10.0.0.1", Host: flexString(long), Status: "up", Timestamp: "2020-01-02T03:04:05Z"},
+		{IPAddress: "10.0.0.0/30", Host: "net.example.com", Status: "down", MAC: "00-1A-2B-3C-4D-5E"},
+	}}
+	normalizeData(data, extra, 16, 0)
+	if data.Hosts[0].Name != "" {
+		t.Fatalf("hostname = %q, want it dropped", data.Hosts[0].Name)
+	}
+	if got := extra.hostStatus(data.Hosts[0]); got != "up" {
+		t.Errorf("status after dropping the hostname = %q, want up", got)
+	}
+	want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if got, ok := extra.hostTimestamp(data.Hosts[0]); !ok || !got.Equal(want) {
+		t.Errorf("timestamp after dropping the hostname = %v, %v, want %v", got, ok, want)
+	}
+
+	hosts, _ := splitCIDRHosts(data.Hosts, []lair.Host{{IPv4: "10.0.0.2"}}, true, extra)
+	if len(hosts) != 2 || hosts[1].IPAddress != "10.0.0.2" {
+		t.Fatalf("hosts = %v, want the CIDR expanded to 10.0.0.2", hosts)
+	}
+	expanded := hosts[1]
+	if got := extra.hostStatus(expanded); got != "down" {
+		t.Errorf("status of an expanded CIDR host = %q, want down", got)
+	}
+	if got, ok := extra.hostMAC(expanded); !ok || got != "00:1a:2b:3c:4d:5e" {
+		t.Errorf("MAC of an expanded CIDR host = %q, %v, want 00:1a:2b:3c:4d:5e", got, ok)
+	}
+
+	// Rewrites chain, so a host whose hostname was dropped and whose address
+	// was then rewritten still finds its rows.
+	moved := reconng.Host{IPAddress: "10.0.0.9"}
+	extra.rekey(data.Hosts[0], moved)
+	if got := extra.hostStatus(moved); got != "up" {
+		t.Errorf("status after two rewrites = %q, want up", got)
+	}
+}
+
+func TestAppendServices(t *testing.T) {
+	existing := []lair.Service{{Port: 443, Protocol: "tcp", Service: "https", LastModifiedBy: "nmap"}}
+	got := appendServices(existing,
+		lair.Service{Port: 80, Protocol: "tcp", Service: "unknown"},
+		lair.Service{Port: 443, Protocol: "TCP", Service: "unknown"},
+		lair.Service{Port: 443, Protocol: "udp", Service: "unknown"},
+	)
+	if len(got) != 3 || got[0].Service != "https" {
+		t.Fatalf("services = %+v, want the existing 443/tcp followed by 80/tcp and 443/udp", got)
+	}
+	if got[1].Port != 80 || got[2].Protocol != "udp" {
+		t.Errorf("services = %+v, want 80/tcp and 443/udp appended", got)
+	}
+}
+
+func TestFieldCoverageReportsUnimportedColumns(t *testing.T) {
+	extra := &extraData{columns: map[string]map[string]int{
+		"hosts":       {"ip_address": 2, "region": 1, "latitude": 1},
+		"credentials": {"username": 1, "type": 1},
+	}}
+	mapped := map[string]bool{}
+	for _, c := range fieldCoverage(extra, nil, false) {
+		mapped[c.Table+"."+c.Column] = c.Mapped
+	}
+	for column, want := range map[string]bool{
+		"hosts.ip_address":     true,
+		"hosts.region":         false,
+		"hosts.latitude":       false,
+		"credentials.username": true,
+		"credentials.type":     false,
+	} {
+		if got, ok := mapped[column]; !ok || got != want {
+			t.Errorf("%s mapped = %v, %v, want %v", column, got, ok, want)
+		}
+	}
+}
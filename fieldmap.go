@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// fieldMap overrides the JSON keys used for recon-ng columns. It is keyed by
+// table name (e.g. "hosts") and then by the column name the recon-ng parser
+// expects (e.g. "ip_address"), with the value being the key actually present
+// in the input. Tables and columns that are not listed are left untouched, so
+// an empty fieldMap preserves the default behavior.
+type fieldMap map[string]map[string]string
+
+// loadFieldMap reads a fieldMap from a JSON file.
+func loadFieldMap(filename string) (fieldMap, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	m := fieldMap{}
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// apply renames the keys in buf, which must be recon-ng JSON, according to
+// the field map and returns the rewritten document.
+func (m fieldMap) apply(buf []byte) ([]byte, error) {
+	if len(m) == 0 {
+		return buf, nil
+	}
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	for table, columns := range m {
+		raw, ok := doc[table]
+		if !ok {
+			continue
+		}
+		rows := []map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			for column, key := range columns {
+				if v, ok := row[key]; ok && key != column {
+					delete(row, key)
+					row[column] = v
+				}
+			}
+		}
+		b, err := json.Marshal(rows)
+		if err != nil {
+			return nil, err
+		}
+		doc[table] = b
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// stringList is a flag.Value that collects every occurrence of a repeatable
+// flag.
+type stringList []string
+
+// String implements flag.Value.
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set implements flag.Value.
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// tags returns the tags held by a tag flag. A flag given once is treated as a
+// comma separated list, while a flag given multiple times holds one tag per
+// occurrence so that tags may contain commas.
+func (s stringList) tags() []string {
+	switch {
+	case len(s) == 1 && s[0] != "":
+		return strings.Split(s[0], ",")
+	case len(s) > 1:
+		return append([]string{}, s...)
+	}
+	return []string{}
+}
+
+// classTags returns the tags for a single data class. The class tags are
+// added to the global tags unless replace is set, in which case they are used
+// in place of the global tags. Without class tags the global tags are used.
+func classTags(global, class []string, replace bool) []string {
+	if len(class) == 0 {
+		return append([]string{}, global...)
+	}
+	if replace {
+		return append([]string{}, class...)
+	}
+	return append(append([]string{}, global...), class...)
+}
+
+// options holds the command line flags, which are described in usage, and
+// the values load reads from the files they name.
+type options struct {
+	showVersion             bool
+	insecureSSL             bool
+	skipVerifyHost          string
+	clientCert              string
+	clientKey               string
+	caCert                  string
+	noFollowRedirects       bool
+	forcePorts              bool
+	forcePortsThreshold     int
+	forceHosts              bool
+	newOnly                 bool
+	tags                    stringList
+	hostTagFlags            stringList
+	netblockTagFlags        stringList
+	peopleTagFlags          stringList
+	replaceClassTags        bool
+	company                 string
+	maxHostnameLength       int
+	maxSubdomainLabels      int
+	batchSize               int
+	validateSchema          bool
+	reportTemplate          string
+	expandCIDR              bool
+	skipExistingNetblocks   bool
+	idFromWorkspace         bool
+	parseOnly               bool
+	date                    string
+	dateNotes               bool
+	onlyNetblocks           bool
+	passthroughUnknown      bool
+	summaryOnlyNotFound     bool
+	matchByHostname         bool
+	failOnNotFound          bool
+	useSyslog               bool
+	syslogAddr              string
+	compareOnly             bool
+	stateFile               string
+	contactsAs              string
+	limit                   int
+	mapStatus               string
+	normalizeEmails         bool
+	sshTunnelTarget         string
+	sshKey                  string
+	sshKnownHosts           string
+	replay                  string
+	sortOutput              bool
+	anonymize               bool
+	anonymizeKeyFile        string
+	excludeHosts            string
+	mergeReportFile         string
+	includeDeleted          bool
+	outputNDJSON            string
+	expectSchema            string
+	ipVersion               string
+	groupTag                bool
+	bestEffort              bool
+	defaultOS               string
+	scopeDomainsFile        string
+	dedupeExport            bool
+	noteFieldFlags          stringList
+	noShrink                bool
+	force                   bool
+	tagFromEmailDomain      bool
+	pretty                  bool
+	netblockContacts        bool
+	fuzzyHostnameMatch      int
+	projectName             string
+	dedupWindow             time.Duration
+	dedupCacheDir           string
+	isolateClasses          bool
+	minConfidence           float64
+	requireConfidence       bool
+	noteReport              bool
+	ignoreCaseTags          bool
+	linkNetblocks           bool
+	verifyFileHashes        stringList
+	portRangeSpec           string
+	mergeByLongIP           bool
+	pushgateway             string
+	hostsFromNetblocks      bool
+	mergeContacts           bool
+	streamImport            bool
+	reconcileByMAC          bool
+	printFieldCoverage      string
+	dedupNetblocksByOverlap bool
+	interactive             bool
+	resumePartial           bool
+	resumeRetries           int
+	verify                  bool
+	format                  string
+	rootKey                 string
+	decodeHTMLEntities      bool
+	fieldMapFile            string
+	previewNotFound         bool
+
+	// Set by load.
+	contactRole  string
+	ports        portRanges
+	scopeDomains []string
+	anonymizeKey []byte
+	excluded     *hostFilter
+	statuses     statusMap
+	parseOpts    parseOptions
+}
+
+// parseFlags parses the command line flags.
+func parseFlags() *options {
+	o := &options{}
+	flag.BoolVar(&o.showVersion, "v", false, "")
+	flag.BoolVar(&o.insecureSSL, "k", false, "")
+	flag.StringVar(&o.skipVerifyHost, "skip-verify-host", "", "")
+	flag.StringVar(&o.clientCert, "client-cert", "", "")
+	flag.StringVar(&o.clientKey, "client-key", "", "")
+	flag.StringVar(&o.caCert, "ca-cert", "", "")
+	flag.BoolVar(&o.noFollowRedirects, "no-follow-redirects", false, "")
+	flag.BoolVar(&o.forcePorts, "force-ports", false, "")
+	flag.IntVar(&o.forcePortsThreshold, "force-ports-threshold", 500, "")
+	flag.BoolVar(&o.forceHosts, "force-hosts", false, "")
+	flag.BoolVar(&o.newOnly, "new-only", false, "")
+	flag.Var(&o.tags, "tags", "")
+	flag.Var(&o.hostTagFlags, "host-tags", "")
+	flag.Var(&o.netblockTagFlags, "netblock-tags", "")
+	flag.Var(&o.peopleTagFlags, "people-tags", "")
+	flag.BoolVar(&o.replaceClassTags, "replace-class-tags", false, "")
+	flag.StringVar(&o.company, "company", "", "")
+	flag.IntVar(&o.maxHostnameLength, "max-hostname-length", 253, "")
+	flag.IntVar(&o.maxSubdomainLabels, "max-subdomain-labels", 0, "")
+	flag.IntVar(&o.batchSize, "batch-size", 0, "")
+	flag.BoolVar(&o.validateSchema, "validate-schema", false, "")
+	flag.StringVar(&o.reportTemplate, "report-template", "", "")
+	flag.BoolVar(&o.expandCIDR, "expand-cidr", false, "")
+	flag.BoolVar(&o.skipExistingNetblocks, "skip-existing-netblocks", false, "")
+	flag.BoolVar(&o.idFromWorkspace, "id-from-workspace", false, "")
+	flag.BoolVar(&o.parseOnly, "parse-only", false, "")
+	flag.StringVar(&o.date, "date", "", "")
+	flag.BoolVar(&o.dateNotes, "date-notes", false, "")
+	flag.BoolVar(&o.onlyNetblocks, "only-netblocks", false, "")
+	flag.BoolVar(&o.passthroughUnknown, "passthrough-unknown", false, "")
+	flag.BoolVar(&o.summaryOnlyNotFound, "summary-only-notfound", false, "")
+	flag.BoolVar(&o.matchByHostname, "match-by-hostname", false, "")
+	flag.BoolVar(&o.failOnNotFound, "fail-on-notfound", false, "")
+	flag.BoolVar(&o.useSyslog, "syslog", false, "")
+	flag.StringVar(&o.syslogAddr, "syslog-addr", "", "")
+	flag.BoolVar(&o.compareOnly, "compare-only", false, "")
+	flag.StringVar(&o.stateFile, "state-file", "", "")
+	flag.StringVar(&o.contactsAs, "contacts-as", "target", "")
+	flag.IntVar(&o.limit, "limit", 0, "")
+	flag.StringVar(&o.mapStatus, "map-status", "", "")
+	flag.BoolVar(&o.normalizeEmails, "normalize-emails", true, "")
+	flag.StringVar(&o.sshTunnelTarget, "ssh-tunnel", "", "")
+	flag.StringVar(&o.sshKey, "ssh-key", "", "")
+	flag.StringVar(&o.sshKnownHosts, "ssh-known-hosts", "", "")
+	flag.StringVar(&o.replay, "replay", "", "")
+	flag.BoolVar(&o.sortOutput, "sort-output", false, "")
+	flag.BoolVar(&o.anonymize, "anonymize", false, "")
+	flag.StringVar(&o.anonymizeKeyFile, "anonymize-key", "", "")
+	flag.StringVar(&o.excludeHosts, "exclude-hosts", "", "")
+	flag.StringVar(&o.mergeReportFile, "merge-report", "", "")
+	flag.BoolVar(&o.includeDeleted, "include-deleted", false, "")
+	flag.StringVar(&o.outputNDJSON, "output-ndjson", "", "")
+	flag.StringVar(&o.expectSchema, "expect-schema", "", "")
+	flag.StringVar(&o.ipVersion, "ip-version", "both", "")
+	flag.BoolVar(&o.groupTag, "group-tag", false, "")
+	flag.BoolVar(&o.bestEffort, "best-effort", false, "")
+	flag.StringVar(&o.defaultOS, "default-os", "", "")
+	flag.StringVar(&o.scopeDomainsFile, "scope-domains", "", "")
+	flag.BoolVar(&o.dedupeExport, "deduplicate-across-export", false, "")
+	flag.Var(&o.noteFieldFlags, "notes-from-field", "")
+	flag.BoolVar(&o.noShrink, "no-shrink", false, "")
+	flag.BoolVar(&o.force, "force", false, "")
+	flag.BoolVar(&o.tagFromEmailDomain, "tag-from-email-domain", false, "")
+	flag.BoolVar(&o.pretty, "pretty", false, "")
+	flag.BoolVar(&o.netblockContacts, "netblock-contacts", false, "")
+	flag.IntVar(&o.fuzzyHostnameMatch, "fuzzy-hostname-match", 0, "")
+	flag.StringVar(&o.projectName, "project-name", "", "")
+	flag.DurationVar(&o.dedupWindow, "dedup-window", 0, "")
+	flag.StringVar(&o.dedupCacheDir, "dedup-cache-dir", defaultCacheDir(), "")
+	flag.BoolVar(&o.isolateClasses, "isolate-classes", false, "")
+	flag.Float64Var(&o.minConfidence, "min-confidence", 0, "")
+	flag.BoolVar(&o.requireConfidence, "require-confidence", false, "")
+	flag.BoolVar(&o.noteReport, "note-report", false, "")
+	flag.BoolVar(&o.ignoreCaseTags, "ignore-case-tags", false, "")
+	flag.BoolVar(&o.linkNetblocks, "link-netblocks", false, "")
+	flag.Var(&o.verifyFileHashes, "verify-file-hash", "")
+	flag.StringVar(&o.portRangeSpec, "port-range", "", "")
+	flag.BoolVar(&o.mergeByLongIP, "merge-by-long-ip", false, "")
+	flag.StringVar(&o.pushgateway, "pushgateway", "", "")
+	flag.BoolVar(&o.hostsFromNetblocks, "hosts-only-from-netblocks", false, "")
+	flag.BoolVar(&o.mergeContacts, "merge-contacts", false, "")
+	flag.BoolVar(&o.streamImport, "stream-import", false, "")
+	flag.BoolVar(&o.reconcileByMAC, "reconcile-by-mac", false, "")
+	flag.StringVar(&o.printFieldCoverage, "print-field-coverage", "", "")
+	flag.BoolVar(&o.dedupNetblocksByOverlap, "dedup-netblocks-by-overlap", false, "")
+	flag.BoolVar(&o.interactive, "interactive", false, "")
+	flag.BoolVar(&o.resumePartial, "resume-on-partial-server-success", false, "")
+	flag.IntVar(&o.resumeRetries, "resume-retries", 3, "")
+	flag.BoolVar(&o.verify, "verify", false, "")
+	flag.StringVar(&o.format, "format", "", "")
+	flag.StringVar(&o.rootKey, "root-key", "", "")
+	flag.BoolVar(&o.decodeHTMLEntities, "decode-html-entities", true, "")
+	flag.StringVar(&o.fieldMapFile, "field-map", "", "")
+	flag.BoolVar(&o.previewNotFound, "preview-notfound", false, "")
+	flag.Usage = func() {
+		fmt.Println(usage)
+	}
+	flag.Parse()
+	return o
+}
+
+// load validates the flags and loads the files they name, exiting on invalid
+// values.
+func (o *options) load() {
+	o.contactRole = strings.ToLower(strings.TrimSpace(o.contactsAs))
+	if o.contactRole == "" || strings.Contains(o.contactRole, ",") {
+		log.Fatal("Fatal: -contacts-as must be a single non-empty role")
+	}
+
+	if o.newOnly && !o.forceHosts {
+		log.Fatal("Fatal: -new-only requires -force-hosts")
+	}
+
+	switch o.format {
+	case "", "json", "xml":
+	default:
+		log.Fatalf("Fatal: Unknown format %s\n", o.format)
+	}
+
+	if o.portRangeSpec != "" {
+		var err error
+		if o.ports, err = parsePortRanges(o.portRangeSpec); err != nil {
+			log.Fatalf("Fatal: Invalid -port-range. Error %s\n", err.Error())
+		}
+	}
+
+	if o.scopeDomainsFile != "" {
+		var err error
+		o.scopeDomains, err = loadScopeDomains(o.scopeDomainsFile)
+		if err != nil {
+			fatalf(exitFileIO, "Fatal: Could not load -scope-domains. Error %s\n", err.Error())
+		}
+	}
+
+	if o.anonymize {
+		if o.anonymizeKeyFile == "" {
+			log.Fatal("Fatal: -anonymize requires -anonymize-key")
+		}
+		var err error
+		o.anonymizeKey, err = loadAnonymizeKey(o.anonymizeKeyFile)
+		if err != nil {
+			fatalf(exitFileIO, "Fatal: Could not load -anonymize-key. Error %s\n", err.Error())
+		}
+	}
+
+	switch o.ipVersion {
+	case "4", "6", "both":
+	default:
+		log.Fatalf("Fatal: Unknown IP version %s\n", o.ipVersion)
+	}
+
+	if o.expectSchema != "" && !schemaVersions[o.expectSchema] {
+		log.Fatalf("Fatal: Unknown schema version %s\n", o.expectSchema)
+	}
+
+	fields := fieldMap{}
+	if o.fieldMapFile != "" {
+		var err error
+		fields, err = loadFieldMap(o.fieldMapFile)
+		if err != nil {
+			log.Fatalf("Fatal: Could not load field map. Error %s\n", err.Error())
+		}
+	}
+
+	if o.excludeHosts != "" {
+		var err error
+		o.excluded, err = loadHostFilter(o.excludeHosts)
+		if err != nil {
+			log.Fatalf("Fatal: Could not load -exclude-hosts. Error %s\n", err.Error())
+		}
+	}
+
+	o.statuses = defaultStatusMap
+	if o.mapStatus != "" {
+		var err error
+		o.statuses, err = loadStatusMap(o.mapStatus)
+		if err != nil {
+			log.Fatalf("Fatal: Could not load status map. Error %s\n", err.Error())
+		}
+	}
+
+	o.parseOpts = parseOptions{
+		format:            o.format,
+		fields:            fields,
+		rootKey:           o.rootKey,
+		decodeHTML:        o.decodeHTMLEntities,
+		includeDeleted:    o.includeDeleted,
+		expectSchema:      o.expectSchema,
+		bestEffort:        o.bestEffort,
+		minConfidence:     o.minConfidence,
+		requireConfidence: o.requireConfidence,
+		fileHashes:        o.verifyFileHashes.tags(),
+	}
+
+	switch o.printFieldCoverage {
+	case "", "text", "json":
+	default:
+		log.Fatalf("Fatal: Invalid -print-field-coverage %s, expected text or json\n", o.printFieldCoverage)
+	}
+
+	if o.company != "" {
+		if name := strings.TrimSpace(o.company); name == "" || strings.Contains(name, ",") {
+			log.Fatal("Fatal: -company must be a single non-empty name")
+		}
+	}
+}
+
+// importTags returns the tags added to the hosts, netblocks and people of an
+// import.
+func (o *options) importTags() (hostTags, netblockTags, peopleTags []string) {
+	hostTags = classTags(o.tags.tags(), o.hostTagFlags.tags(), o.replaceClassTags)
+	netblockTags = classTags(o.tags.tags(), o.netblockTagFlags.tags(), o.replaceClassTags)
+	peopleTags = classTags(o.tags.tags(), o.peopleTagFlags.tags(), o.replaceClassTags)
+	if o.company != "" {
+		companyTag := "company:" + strings.TrimSpace(o.company)
+		hostTags = append(hostTags, companyTag)
+		netblockTags = append(netblockTags, companyTag)
+		peopleTags = append(peopleTags, companyTag)
+	}
+	if o.ignoreCaseTags {
+		hostTags = foldTags(hostTags, nil)
+		netblockTags = foldTags(netblockTags, nil)
+		peopleTags = foldTags(peopleTags, nil)
+	}
+	return hostTags, netblockTags, peopleTags
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// parseTags parses args into a -tags flag and returns its tags.
+func parseTags(t *testing.T, args ...string) []string {
+	t.Helper()
+	var tags stringList
+	fs := flag.NewFlagSet("drone-recon-ng", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Var(&tags, "tags", "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return tags.tags()
+}
+
+func TestTagsFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{}},
+		{[]string{"-tags", "a,b"}, []string{"a", "b"}},
+		{[]string{"-tags", "a", "-tags", "b"}, []string{"a", "b"}},
+		{[]string{"-tags", "acme, inc", "-tags", "external"}, []string{"acme, inc", "external"}},
+	}
+	for _, tt := range tests {
+		if got := parseTags(t, tt.args...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tags for %q = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
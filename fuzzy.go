@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+
+	lair "github.com/lair-framework/go-lair"
+)
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// fuzzyHostByHostname returns the index of the host in hosts with the
+// hostname closest to name, within maxDistance edits, along with that
+// hostname and its distance. The same names as hostByHostname never match,
+// and neither does a name that is equally close to hostnames of more than one
+// host.
+func fuzzyHostByHostname(hosts []lair.Host, name string, maxDistance int) (int, string, int, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if !strings.Contains(name, ".") || strings.HasPrefix(name, "localhost.") {
+		return 0, "", 0, false
+	}
+	match, best, stored := -1, maxDistance+1, ""
+	ambiguous := false
+	for i, h := range hosts {
+		for _, hn := range h.Hostnames {
+			d := levenshtein(strings.ToLower(strings.TrimSuffix(hn, ".")), name)
+			switch {
+			case d < best:
+				match, best, stored, ambiguous = i, d, hn, false
+			case d == best && match != i:
+				ambiguous = true
+			}
+		}
+	}
+	if match == -1 || ambiguous {
+		return 0, "", 0, false
+	}
+	return match, stored, best, true
+}
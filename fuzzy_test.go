@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	lair "github.com/lair-framework/go-lair"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"www.example.com", "www.example.com", 0},
+		{"www.example.com", "ww.example.com", 1},
+		{"www.example.com", "www.exmaple.com", 2},
+		{"mail.example.com", "mial.exampel.co", 5},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyHostByHostname(t *testing.T) {
+	hosts := []lair.Host{
+		{IPv4: "10.0.0.1", Hostnames: []string{"www.example.com"}},
+		{IPv4: "10.0.0.2", Hostnames: []string{"mail.example.com"}},
+	}
+	i, stored, distance, ok := fuzzyHostByHostname(hosts, "ww.example.com", 2)
+	if !ok || i != 0 || stored != "www.example.com" || distance != 1 {
+		t.Errorf("distance 1 = %d, %q, %d, %v, want a match to www.example.com", i, stored, distance, ok)
+	}
+	if _, _, _, ok := fuzzyHostByHostname(hosts, "w.exmple.com", 2); ok {
+		t.Error("distance 3 matched with a maximum of 2")
+	}
+
+	// A name as close to two hosts never matches.
+	hosts = append(hosts, lair.Host{IPv4: "10.0.0.3", Hostnames: []string{"wwx.example.com"}})
+	if _, _, _, ok := fuzzyHostByHostname(hosts, "wwy.example.com", 2); ok {
+		t.Error("a name equally close to two hosts matched")
+	}
+}
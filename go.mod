@@ -0,0 +1,8 @@
+module github.com/lair-framework/drone-recon-ng
+
+go 1.26.0
+
+require (
+	github.com/prometheus/client_golang v1.24.1
+	golang.org/x/crypto v0.57.0
+)
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/mail"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/lair-framework/api-server/client"
 	lair "github.com/lair-framework/go-lair"
@@ -19,56 +26,313 @@ const (
 	version = "1.1.0"
 	tool    = "recon-ng"
 	usage   = `
-	Parses a recon-ng JSON file into a lair project.
+	Parses one or more recon-ng JSON or XML files into a lair project.
 	Usage:
-	drone-recon-ng [options] <id> <filename>
+	drone-recon-ng [options] <id> <filename> [<filename>...]
 	export LAIR_ID=<id>; drone-recon-ng [options] <filename>
+	drone-recon-ng [options] -replay <project.json> [<id>]
+	A filename of - reads the recon-ng data from stdin.
 	Options:
 	-v              show version and exit
 	-h              show usage and exit
 	-k              allow insecure SSL connections
+	-skip-verify-host
+	                do not verify the certificate of this host name or IP address,
+	                all other hosts are verified as usual
+	-client-cert    a PEM encoded client certificate used to authenticate to the API server
+	-client-key     the PEM encoded private key for -client-cert
+	-ca-cert        a PEM encoded CA bundle used to verify the API server instead of
+	                the system roots
+	-no-follow-redirects
+	                do not follow redirects returned by the API server
 	-force-ports    disable data protection in the API server for excessive ports
+	-force-ports-threshold
+	                warn before importing when a host has more services than this
+	                and -force-ports is not set, 0 disables the warning (default 500)
 	-force-hosts    only import hosts that have listening ports
-	-tags           a comma separated list of tags to add to every host that is imported
+	-new-only       only import hosts that do not already exist in lair, leaving existing
+	                hosts untouched. Requires -force-hosts
+	-tags           a tag to add to everything that is imported. May be given multiple
+	                times, when given once it is treated as a comma separated list.
+	                Hosts are tagged directly, netblocks record their tags in the
+	                description and people record them as groups
+	-host-tags      tags for hosts only, given in the same way as -tags
+	-netblock-tags  tags for netblocks only, given in the same way as -tags
+	-people-tags    tags for people only, given in the same way as -tags
+	-replace-class-tags
+	                use the class specific tags in place of -tags instead of in addition
+	                to them, for the classes where they are given
+	-company        the name of the organization the data belongs to, added as a
+	                "company:<name>" tag to hosts, netblocks and people
+	-max-hostname-length
+	                hostnames longer than this are skipped with a warning, 0 disables
+	                the check (default 253)
+	-max-subdomain-labels
+	                hostnames with more dot-separated labels than this are skipped
+	                with a warning as likely crawler or wildcard DNS noise, 0
+	                disables the check (default 0)
+	-batch-size     the maximum number of hosts to send in a single import request, 0
+	                sends all hosts in one request (default 0)
+	-validate-schema
+	                check the recon-ng data and the assembled lair project for missing
+	                or malformed fields, report every violation and exit without importing
+	-report-template
+	                a Go text/template file used to render the summary printed after
+	                the import, in place of the default log output
+	-expand-cidr    when a recon-ng host has a CIDR as its ip_address, add its hostname to
+	                every lair host in the range instead of importing the CIDR as a netblock
+	-skip-existing-netblocks
+	                do not import netblocks whose CIDR already exists in the project
+	-id-from-workspace
+	                when no id is given, use the recon-ng workspace name in the export
+	                as the lair project id
+	-parse-only     parse and normalize the recon-ng files given as arguments, print the
+	                result as JSON and exit. No lair environment variables are needed
+	-print-field-coverage
+	                parse the recon-ng files given as arguments and print which
+	                columns of each table are mapped and which are ignored, as
+	                text or json, then exit. With -parse-only the coverage is
+	                written to stderr
+	-date           the RFC3339 time the recon-ng data was collected, recorded in a
+	                "recon-ng collected" project note (default the current time)
+	-date-notes     record -date, or the current time if it is not set, as a note on
+	                every host that is imported or updated
+	-only-netblocks only import netblocks, ignoring all other recon-ng data
+	-passthrough-unknown
+	                import every row of the recon-ng tables the drone does not handle as
+	                a project note titled with the table name
+	-summary-only-notfound
+	                only print the IPs of the recon-ng hosts that were not in lair,
+	                printing nothing when every host matched
+	-match-by-hostname
+	                merge recon-ng hosts that are not in lair onto the lair host with the
+	                same hostname, recording the recon-ng IP as an additional address
+	-fail-on-notfound
+	                exit without importing if any recon-ng host does not exist in lair,
+	                unless -force-hosts is set
+	-syslog         also send log output, and an audit record of the import, to syslog
+	-syslog-addr    the syslog server to use with -syslog, as [tcp|udp://]host:port.
+	                When not set the local syslog daemon is used
+	-compare-only   print the changes the import would make to the project, one per line,
+	                and exit without importing
+	-state-file     a file recording the latest discovery time seen in the recon-ng
+	                timestamp column. Only rows discovered after it are imported, and
+	                it is updated after every successful import
+	-contacts-as    the role of the imported contacts, such as target or internal,
+	                recorded on each person as a "role:<role>" group (default target)
+	-limit          import at most this many recon-ng hosts, netblocks and contacts each,
+	                keeping the first after sorting by IP, CIDR and email. 0 disables
+	                the limit (default 0)
+	-map-status     a JSON file translating the recon-ng host status column to lair
+	                statuses, e.g. {"owned": {"status": "lair-red", "message": "shell"}}.
+	                By default common values such as owned and interesting are mapped
+	-normalize-emails
+	                lowercase and validate contact emails, dropping invalid emails while
+	                still importing the contact (default true)
+	-ssh-tunnel     reach the lair API server through an SSH bastion given as
+	                user@host[:port]
+	-ssh-key        the private key to authenticate to the -ssh-tunnel bastion with
+	-ssh-known-hosts
+	                the known_hosts file used to verify the -ssh-tunnel bastion
+	                (default ~/.ssh/known_hosts)
+	-replay         import a previously saved lair project JSON file as is, skipping
+	                parsing and reconciliation
+	-sort-output    sort hosts, hostnames, netblocks and people in the import so the
+	                same input always produces the same payload
+	-anonymize      replace contact names, emails and phone numbers with one-way
+	                placeholders keyed with the secret in -anonymize-key, and drop
+	                their address, department and description. This cannot be
+	                undone in lair
+	-anonymize-key  a file holding the secret -anonymize uses to derive placeholders.
+	                No key reverses a placeholder, but anyone holding it can confirm
+	                a guessed name or email, keep it private
+	-exclude-hosts  a file, or a comma separated list, of IPs, CIDR ranges and
+	                hostnames that are never imported
+	-merge-report   write a JSON object mapping each lair host IP to the hostnames
+	                it gained in this import to the given file
+	-include-deleted
+	                import rows marked as deleted, hidden or trash in recon-ng, which
+	                are skipped by default
+	-output-ndjson  write every host, netblock and person sent to lair to the given
+	                file as one JSON object per line with a "type" field
+	-expect-schema  fail unless the recon-ng export looks like a v4 or v5 export,
+	                told apart by the notes column recon-ng 5 added. Both versions
+	                are decoded the same way
+	-ip-version     only import hosts with IPv4 (4) or IPv6 (6) addresses, or both
+	                (default both)
+	-group-tag      tag every imported host with "workspace:<name>" for each recon-ng
+	                workspace it was exported from
+	-best-effort    import the tables that were complete in a truncated recon-ng
+	                JSON file instead of failing
+	-default-os     the OS fingerprint given to imported hosts that have no OS, both
+	                forced and existing hosts, an existing OS is never replaced
+	-scope-domains  a file listing the in scope parent domains one per line, hosts
+	                with a hostname outside them are skipped
+	-deduplicate-across-export
+	                merge lair hosts that share an IP into a single host, combining
+	                their hostnames, services, tags and notes, before reconciling
+	-notes-from-field
+	                a recon-ng hosts column whose values are added to the host as a
+	                "recon-ng <column>" note, may be given multiple times
+	-no-shrink      refuse to import when the import holds fewer hosts than the
+	                exported project
+	-force          import even when a -no-shrink check fails
+	-tag-from-email-domain
+	                add an "org:<domain>" group to each contact from the domain of
+	                their email
+	-pretty         print the import summary as aligned tables
+	-netblock-contacts
+	                import the email and org handle of each netblock as a person,
+	                unless a contact with the same email is already imported
+	-fuzzy-hostname-match
+	                merge recon-ng hosts that are not in lair onto the lair host with
+	                the closest hostname within this many edits, 0 disables fuzzy
+	                matching (default 0)
+	-project-name   when no id is given, look up the id of the lair project with this
+	                name
+	-dedup-window   skip the import when the same input was imported into the same
+	                project within this duration, such as 1h
+	-dedup-cache-dir
+	                the directory -dedup-window records imports in (default the
+	                drone-recon-ng directory in the user cache directory)
+	-isolate-classes
+	                import hosts, netblocks, people and credentials in separate
+	                requests so a failure in one class does not stop the others
+	-min-confidence skip recon-ng rows with a confidence column below this value
+	-require-confidence
+	                also skip recon-ng rows without a confidence column
+	-note-report    add a project note holding the import counts, the hosts not found
+	                in lair and any hostname conflicts
+	-ignore-case-tags
+	                lowercase the imported tags and skip tags a host already has in
+	                a different case
+	-link-netblocks add a note to each imported host naming the most specific netblock
+	                that contains it
+	-verify-file-hash
+	                the expected SHA-256 of the input file, the import is aborted if
+	                it differs, given once per file or comma separated in file order
+	-port-range     only import services on these ports, given as a comma separated
+	                list of ports and ranges such as 1-1024,8080,8443
+	-merge-by-long-ip
+	                match recon-ng hosts to lair hosts by their IPv4 address as an
+	                integer, so that addresses such as 10.0.0.01 match 10.0.0.1
+	-pushgateway    the URL of a Prometheus pushgateway to push the run's metrics to,
+	                grouped by project id
+	-hosts-only-from-netblocks
+	                only import hosts whose IP address is in one of the
+	                project's or the recon-ng data's netblocks
+	-merge-contacts merge contacts into existing people with the same email,
+	                filling in the details they lack, instead of adding new people
+	-stream-import  stream each import request body to the API server as it is
+	                encoded instead of marshaling the whole project in memory first.
+	                The request is the same PATCH the drone always sends
+	-reconcile-by-mac
+	                match recon-ng hosts to lair hosts by MAC address before IP
+	                address, for networks where addresses change
+	-dedup-netblocks-by-overlap
+	                skip netblocks within an existing or larger imported netblock,
+	                merging their handle, emails and tags onto it
+	-interactive    prompt on stdin before overwriting an existing host status or OS
+	                or creating a new host, ignored when stdin is not a terminal
+	-resume-on-partial-server-success
+	                like -verify, but hosts missing after the import are imported
+	                again, up to -resume-retries times
+	-resume-retries the number of times -resume-on-partial-server-success retries
+	                (default 3)
+	-verify         re-export the project after importing and confirm the data landed
+	-format         the format of the input files, json or xml. When not set the format
+	                is detected from the file extension
+	-root-key       the key the recon-ng tables are nested under. When not set a single
+	                level of nesting is detected automatically
+	-decode-html-entities
+	                decode HTML entities such as &amp; in hostnames, contacts and
+	                companies. Use -decode-html-entities=false to keep the raw values
+	                (default true)
+	-field-map      a JSON file overriding the keys used for recon-ng columns, e.g.
+	                {"hosts": {"ip_address": "ip", "host": "hostname"}}
+	-preview-notfound
+	                print the hosts that are not in lair and would need -force-hosts
+	                to be imported, along with their hostnames, then exit
 	`
 )
 
+// Exit codes used for failures that callers may want to distinguish.
+const (
+	exitError  = 1
+	exitAuth   = 3
+	exitFileIO = 4
+	exitScope  = 5
+
+	exitInterrupted = 130
+)
+
+const authFailed = "Fatal: Authentication failed, check the LAIR_API_SERVER credentials"
+
 func main() {
-	showVersion := flag.Bool("v", false, "")
-	insecureSSL := flag.Bool("k", false, "")
-	forcePorts := flag.Bool("force-ports", false, "")
-	forceHosts := flag.Bool("force-hosts", false, "")
-	tags := flag.String("tags", "", "")
-	flag.Usage = func() {
-		fmt.Println(usage)
-	}
-	flag.Parse()
+	started := time.Now()
+	o := parseFlags()
 
-	if *showVersion {
+	if o.showVersion {
 		log.Println(version)
 		os.Exit(0)
 	}
 
-	tagSet := map[string]bool{}
+	var audit io.Writer
+	if o.useSyslog {
+		w, err := dialSyslog(o.syslogAddr)
+		if err != nil {
+			log.Printf("Warning: Could not connect to syslog, continuing without it. Error %s\n", err.Error())
+		} else {
+			audit = w
+			log.SetOutput(io.MultiWriter(os.Stderr, w))
+		}
+	}
+
+	o.load()
+
+	if o.parseOnly || o.printFieldCoverage != "" {
+		printParsed(o)
+		os.Exit(0)
+	}
+
+	collected := time.Now().UTC()
+	if o.date != "" {
+		t, err := time.Parse(time.RFC3339, o.date)
+		if err != nil {
+			log.Fatalf("Fatal: Invalid -date, expected RFC3339. Error %s\n", err.Error())
+		}
+		collected = t
+	}
+	collectedAt := collected.Format(time.RFC3339)
+
 	lairURL := os.Getenv("LAIR_API_SERVER")
 
 	if lairURL == "" {
 		log.Fatal("Fatal: Missing LAIR_API_SERVER environment variable")
 	}
 
-	lairPID := os.Getenv("LAIR_ID")
-	var filename string
-	switch len(flag.Args()) {
-	case 2:
+	envPID := os.Getenv("LAIR_ID")
+	lairPID := envPID
+	var filenames []string
+	switch {
+	case o.replay != "":
+		if len(flag.Args()) > 0 {
+			lairPID = flag.Arg(0)
+		}
+	case len(flag.Args()) >= 2:
 		lairPID = flag.Arg(0)
-		filename = flag.Arg(1)
-	case 1:
-		filename = flag.Arg(0)
+		filenames = flag.Args()[1:]
+	case len(flag.Args()) == 1:
+		filenames = flag.Args()
 	default:
 		log.Fatal("Fatal: Missing required argument")
 	}
 
-	if lairPID == "" {
+	if envPID != "" && lairPID != envPID {
+		log.Printf("Warning: Importing into project %s given on the command line, not LAIR_ID %s\n", lairPID, envPID)
+	}
+
+	if lairPID == "" && !o.idFromWorkspace && o.replay == "" && o.projectName == "" {
 		log.Fatal("Fatal: Missing LAIR_ID")
 	}
 
@@ -86,149 +350,237 @@ func main() {
 	if user == "" || pass == "" {
 		log.Fatal("Fatal: Missing username and/or password")
 	}
-	c, err := client.New(&client.COptions{
-		User:               user,
-		Password:           pass,
-		Host:               u.Host,
-		Scheme:             u.Scheme,
-		InsecureSkipVerify: *insecureSSL,
-	})
 
-	if err != nil {
-		log.Fatalf("Fatal: Error setting up client: Error %s\n", err.Error())
+	ctx := interruptContext()
+	c, tunnel := newClient(ctx, o, u, user, pass)
+	if tunnel != nil {
+		defer tunnel.Close()
 	}
 
-	buf, err := ioutil.ReadFile(filename)
-	if err != nil {
-		log.Fatalf("Fatal: Could not open file. Error %s\n", err.Error())
+	if lairPID == "" && o.projectName != "" {
+		id, err := c.ProjectIDByName(o.projectName)
+		if err != nil {
+			if err == context.Canceled {
+				fatalf(exitInterrupted, "Fatal: Interrupted\n")
+			}
+			if isAuthError(err) {
+				fatalf(exitAuth, "%s. Error %s\n", authFailed, err.Error())
+			}
+			log.Fatalf("Fatal: Could not look up project %s. Error %s\n", o.projectName, err.Error())
+		}
+		log.Printf("Info: Using project %s for %s\n", id, o.projectName)
+		lairPID = id
 	}
 
-	rNotFound := map[string][]reconng.Host{}
-	recData, err := reconng.Parse(buf)
-	if err != nil {
-		log.Fatalf("Fatal: Error parsing recon-ng data. Error %s\n", err.Error())
+	if o.replay != "" {
+		replayProject(ctx, c, o, lairPID)
+		os.Exit(0)
 	}
-	hostTags := []string{}
-	if *tags != "" {
-		hostTags = strings.Split(*tags, ",")
+
+	var reportTmpl *template.Template
+	if o.reportTemplate != "" {
+		var err error
+		reportTmpl, err = loadReportTemplate(o.reportTemplate)
+		if err != nil {
+			log.Fatalf("Fatal: Could not load report template. Error %s\n", err.Error())
+		}
 	}
 
-	exproject, err := c.ExportProject(lairPID)
-	if err != nil {
-		log.Fatalf("Fatal: Unable to export project. Error %s\n", err.Error())
+	recData, recExtra := mustParseFiles(filenames, o.parseOpts, o.maxHostnameLength, o.maxSubdomainLabels)
+
+	state := applyState(o, recData, recExtra)
+
+	if lairPID == "" {
+		switch len(recExtra.workspaces) {
+		case 0:
+			log.Fatal("Fatal: Missing LAIR_ID and the recon-ng data has no workspace name")
+		case 1:
+			lairPID = recExtra.workspaces[0]
+			log.Printf("Info: Using workspace name %s as the lair project id\n", lairPID)
+		default:
+			log.Fatalf("Fatal: Missing LAIR_ID and the recon-ng data has multiple workspace names: %s\n", strings.Join(recExtra.workspaces, ", "))
+		}
 	}
 
-	project := &lair.Project{
-		ID:   lairPID,
-		Tool: tool,
-		Commands: []lair.Command{lair.Command{
-			Tool: tool,
-		}},
+	var dedupCache *importCache
+	dedupKey := ""
+	if o.dedupWindow > 0 {
+		dedupCache = &importCache{dir: o.dedupCacheDir}
+		var err error
+		if dedupKey, err = importKey(lairPID, filenames); err != nil {
+			// Input read from stdin cannot be read a second time.
+			log.Printf("Warning: Could not hash the input, -dedup-window is disabled. Error %s\n", err.Error())
+			dedupCache = nil
+		} else if at, ok := dedupCache.seen(dedupKey, o.dedupWindow); ok {
+			log.Printf("Info: The same input was imported into %s at %s, skipping\n", lairPID, at.Format(time.RFC3339))
+			os.Exit(0)
+		}
 	}
 
-	for _, result := range recData.Hosts {
-		found := false
-		for i := range exproject.Hosts {
-			h := exproject.Hosts[i]
-			if result.IPAddress == h.IPv4 {
-				exproject.Hosts[i].Hostnames = append(exproject.Hosts[i].Hostnames, result.Name)
-				exproject.Hosts[i].LastModifiedBy = tool
-				found = true
-				if _, ok := tagSet[h.IPv4]; !ok {
-					tagSet[h.IPv4] = true
-					exproject.Hosts[i].Tags = append(exproject.Hosts[i].Tags, hostTags...)
-				}
-			}
+	if o.onlyNetblocks {
+		recData = &reconng.Data{NetBlocks: recData.NetBlocks}
+		recExtra = &extraData{}
+	}
+
+	exproject := exportProject(c, o, lairPID)
+
+	var prompt *prompter
+	if o.interactive {
+		if prompt = newPrompter(ctx); prompt == nil {
+			log.Println("Warning: stdin is not a terminal, -interactive is disabled")
 		}
-		if !found && result.IPAddress != "" {
-			rNotFound[result.IPAddress] = append(rNotFound[result.IPAddress], result)
+	}
+
+	r := newReconciler(o, exproject, recData, recExtra, prompt, collectedAt)
+	r.reconcileHosts()
+	rNotFound := r.notFound
+
+	if o.previewNotFound {
+		ips := sortedIPs(rNotFound)
+		for _, ip := range ips {
+			fmt.Printf("%s %s\n", ip, strings.Join(resultHostnames(rNotFound[ip]), ","))
 		}
+		log.Printf("Info: %d hosts are not in lair, re-run with -force-hosts to import them\n", len(ips))
+		os.Exit(0)
 	}
 
-	for _, h := range exproject.Hosts {
-		project.Hosts = append(project.Hosts, lair.Host{
-			IPv4:           h.IPv4,
-			LongIPv4Addr:   h.LongIPv4Addr,
-			IsFlagged:      h.IsFlagged,
-			LastModifiedBy: h.LastModifiedBy,
-			MAC:            h.MAC,
-			OS:             h.OS,
-			Status:         h.Status,
-			StatusMessage:  h.StatusMessage,
-			Tags:           hostTags,
-			Hostnames:      h.Hostnames,
+	if o.failOnNotFound && !o.forceHosts && len(rNotFound) > 0 {
+		fatalf(exitScope, "Fatal: %d recon-ng hosts do not exist in lair: %s\n", len(rNotFound), strings.Join(sortedIPs(rNotFound), ", "))
+	}
+
+	project := r.buildProject()
+	exported := r.exported
+
+	if o.noteReport {
+		report := newImportReport(project, filenames, r.updated, rNotFound, o.forceHosts)
+		project.Notes = append(project.Notes, lair.Note{
+			Title:          "recon-ng import " + time.Now().UTC().Format(time.RFC3339),
+			Content:        report.noteContent(r.divergences),
+			LastModifiedBy: tool,
 		})
 	}
 
-	if *forceHosts {
-		for ip, results := range rNotFound {
-			hostnames := []string{}
-			for _, r := range results {
-				hostnames = append(hostnames, r.Name)
+	if o.sortOutput {
+		sortProject(project)
+	}
+
+	if o.compareOnly {
+		for _, change := range changeset(&exported, project, rNotFound, o.forceHosts) {
+			fmt.Println(change)
+		}
+		os.Exit(0)
+	}
+
+	if o.validateSchema {
+		violations := append(validateData(recData), validateProject(project)...)
+		for _, v := range violations {
+			log.Printf("Error: %s\n", v)
+		}
+		if len(violations) > 0 {
+			fatalf(exitError, "Fatal: Found %d schema violations\n", len(violations))
+		}
+		log.Println("Success: No schema violations found")
+		os.Exit(0)
+	}
+
+	// -new-only and -only-netblocks send a subset of the hosts by design.
+	if o.noShrink && !o.newOnly && !o.onlyNetblocks {
+		log.Printf("Info: The project has %d hosts and the import holds %d hosts\n", len(exported.Hosts), len(project.Hosts))
+		if len(project.Hosts) < len(exported.Hosts) {
+			if !o.force {
+				fatalf(exitError, "Fatal: The import would shrink the project from %d to %d hosts, use -force to import anyway\n", len(exported.Hosts), len(project.Hosts))
 			}
-			project.Hosts = append(project.Hosts, lair.Host{
-				IPv4:      ip,
-				Hostnames: hostnames,
-			})
+			log.Printf("Warning: The import shrinks the project from %d to %d hosts\n", len(exported.Hosts), len(project.Hosts))
 		}
 	}
 
-	for _, p := range recData.NetBlocks {
-		nb := lair.Netblock{}
-		nb.ProjectID = project.ID
-		nb.MiscEmails = p.Email
-		nb.CIDR = p.Netblock
-		nb.Handle = p.OrgHandle
-		project.Netblocks = append(project.Netblocks, nb)
+	if o.outputNDJSON != "" {
+		if err := writeNDJSON(o.outputNDJSON, project); err != nil {
+			fatalf(exitFileIO, "Fatal: Could not write -output-ndjson. Error %s\n", err.Error())
+		}
 	}
 
-	for _, c := range recData.Contacts {
-		per := lair.Person{}
-		per.ProjectID = exproject.ID
-		per.PrincipalName = c.Email
-		per.FirstName = c.FirstName
-		per.MiddleName = c.MiddleName
-		per.LastName = c.LastName
-		per.Emails = append(per.Emails, c.Email)
-		per.Address = c.Region
-		per.Department = c.Title
-		project.People = append(project.People, per)
+	serverMessages := importAndVerify(ctx, c, o, project)
+
+	for _, msg := range serverMessages {
+		log.Printf("Warning: The API server reported: %s\n", msg)
 	}
 
-	for _, cred := range recData.Credentials {
-		lc := lair.Credential{}
-		lc.ProjectID = exproject.ID
-		lc.Username = cred.Username
-		lc.Hash = cred.Hash
-		lc.Password = cred.Password
-		lc.Service = cred.Leak
-		project.Credentials = append(project.Credentials, lc)
+	if o.stateFile != "" {
+		if err := saveState(o.stateFile, state); err != nil {
+			log.Printf("Warning: Could not write state file. Error %s\n", err.Error())
+		}
 	}
 
-	res, err := c.ImportProject(&client.DOptions{ForcePorts: *forcePorts}, project)
+	if dedupCache != nil {
+		if err := dedupCache.record(dedupKey); err != nil {
+			log.Printf("Warning: Could not write -dedup-window cache. Error %s\n", err.Error())
+		}
+	}
 
-	if err != nil {
-		log.Fatalf("Fatal: Unable to import project. Error %s\n", err)
+	if o.pushgateway != "" {
+		added := 0
+		for _, names := range newHostnames(&exported, project) {
+			added += len(names)
+		}
+		notFound := len(rNotFound)
+		if o.forceHosts {
+			notFound = 0
+		}
+		err := pushMetrics(o.pushgateway, lairPID, runMetrics{
+			HostsImported:   len(project.Hosts),
+			HostnamesAdded:  added,
+			Netblocks:       len(project.Netblocks),
+			People:          len(project.People),
+			NotFound:        notFound,
+			DurationSeconds: time.Since(started).Seconds(),
+		})
+		if err != nil {
+			log.Printf("Warning: Could not push metrics to %s. Error %s\n", o.pushgateway, err.Error())
+		}
 	}
 
-	defer res.Body.Close()
-	droneRes := &client.Response{}
-	body, err := ioutil.ReadAll(res.Body)
+	if audit != nil {
+		fmt.Fprintf(audit, "Imported %d hosts, %d netblocks, %d people and %d credentials from %s into project %s as %s\n",
+			len(project.Hosts), len(project.Netblocks), len(project.People), len(project.Credentials), strings.Join(filenames, ", "), lairPID, user)
+	}
 
-	if err != nil {
-		log.Fatalf("Fatal: Error %s", err.Error())
+	if o.mergeReportFile != "" {
+		buf, err := json.MarshalIndent(newHostnames(&exported, project), "", "  ")
+		if err != nil {
+			log.Fatalf("Fatal: Could not marshal merge report. Error %s\n", err.Error())
+		}
+		if err := ioutil.WriteFile(o.mergeReportFile, buf, 0644); err != nil {
+			fatalf(exitFileIO, "Fatal: Could not write merge report. Error %s\n", err.Error())
+		}
 	}
 
-	if err := json.Unmarshal(body, droneRes); err != nil {
-		log.Fatalf("Fatal: Could not unmarshal JSON. Error %s\n", err.Error())
+	if o.summaryOnlyNotFound {
+		for _, ip := range sortedIPs(rNotFound) {
+			fmt.Println(ip)
+		}
+		return
 	}
 
-	if droneRes.Status == "Error" {
-		log.Fatalf("Fatal: Import failed. Error %s\n", droneRes.Message)
+	if reportTmpl != nil {
+		report := newImportReport(project, filenames, r.updated, rNotFound, o.forceHosts)
+		report.ServerMessages = serverMessages
+		if err := report.render(reportTmpl); err != nil {
+			log.Fatalf("Fatal: Could not render report template. Error %s\n", err.Error())
+		}
+		return
+	}
+
+	if o.pretty {
+		report := newImportReport(project, filenames, r.updated, rNotFound, o.forceHosts)
+		if err := report.pretty(os.Stdout); err != nil {
+			log.Fatalf("Fatal: Could not write summary. Error %s\n", err.Error())
+		}
+		return
 	}
 
 	if len(rNotFound) > 0 {
-		if *forceHosts {
+		if o.forceHosts {
 			log.Println("Info: The following hosts had hostnames and were forced to import into lair")
 		} else {
 			log.Println("Info: The following hosts had hostnames but could not be imported because they do not exist in lair")
@@ -241,3 +593,654 @@ func main() {
 
 	log.Println("Success: Operation completed successfully")
 }
+
+// printParsed prints the parsed input files for -parse-only and their field
+// coverage for -print-field-coverage.
+func printParsed(o *options) {
+	if len(flag.Args()) == 0 {
+		log.Fatal("Fatal: Missing required argument")
+	}
+	recData, recExtra := mustParseFiles(flag.Args(), o.parseOpts, o.maxHostnameLength, o.maxSubdomainLabels)
+	if o.parseOnly {
+		buf, err := json.MarshalIndent(recData, "", "  ")
+		if err != nil {
+			log.Fatalf("Fatal: Could not marshal JSON. Error %s\n", err.Error())
+		}
+		fmt.Println(string(buf))
+	}
+	if o.printFieldCoverage != "" {
+		// The parsed data owns stdout with -parse-only.
+		w := io.Writer(os.Stdout)
+		if o.parseOnly {
+			w = os.Stderr
+		}
+		coverage := fieldCoverage(recExtra, o.noteFieldFlags, o.passthroughUnknown)
+		if err := writeCoverage(w, coverage, o.printFieldCoverage == "json"); err != nil {
+			log.Fatalf("Fatal: Could not write field coverage. Error %s\n", err.Error())
+		}
+	}
+}
+
+// replayProject imports the lair project saved in the -replay file into
+// lairPID, or the project's own id when lairPID is empty.
+func replayProject(ctx context.Context, c apiClient, o *options, lairPID string) {
+	buf, err := ioutil.ReadFile(o.replay)
+	if err != nil {
+		fatalf(exitFileIO, "Fatal: Could not open replay file. Error %s\n", err.Error())
+	}
+	project := &lair.Project{}
+	if err := json.Unmarshal(buf, project); err != nil {
+		log.Fatalf("Fatal: Replay file is not a lair project. Error %s\n", err.Error())
+	}
+	if violations := validateProject(project); len(violations) > 0 {
+		for _, v := range violations {
+			log.Printf("Warning: Schema violation: %s\n", v)
+		}
+		fatalf(exitError, "Fatal: Replay file has %d schema violations\n", len(violations))
+	}
+	if lairPID != "" {
+		project.ID = lairPID
+	}
+	if project.ID == "" {
+		log.Fatal("Fatal: Missing LAIR_ID")
+	}
+	for _, msg := range mustImport(ctx, c, &client.DOptions{ForcePorts: o.forcePorts}, project, o.batchSize, o.forcePortsThreshold) {
+		log.Printf("Warning: The API server reported: %s\n", msg)
+	}
+	log.Printf("Success: Replayed %d hosts into project %s\n", len(project.Hosts), project.ID)
+}
+
+// applyState drops the recon-ng hosts and ports discovered before the last
+// run recorded in the -state-file, and returns the state to save after the
+// import.
+func applyState(o *options, recData *reconng.Data, recExtra *extraData) runState {
+	state := runState{}
+	if o.stateFile == "" {
+		return state
+	}
+	var err error
+	state, err = loadState(o.stateFile)
+	switch {
+	case os.IsNotExist(err):
+		log.Println("Info: State file does not exist, importing all data")
+	case err != nil:
+		log.Printf("Warning: Could not load state file, importing all data. Error %s\n", err.Error())
+		state = runState{}
+	}
+	if !state.LastSeen.IsZero() {
+		hosts := []reconng.Host{}
+		for _, result := range recData.Hosts {
+			if t, ok := recExtra.hostTimestamp(result); !ok || t.After(state.LastSeen) {
+				hosts = append(hosts, result)
+			}
+		}
+		ports := []reconPort{}
+		for _, p := range recExtra.Ports {
+			if t, ok := parseTimestamp(p.Timestamp); !ok || t.After(state.LastSeen) {
+				ports = append(ports, p)
+			}
+		}
+		log.Printf("Info: Skipping %d hosts and %d ports discovered before %s\n",
+			len(recData.Hosts)-len(hosts), len(recExtra.Ports)-len(ports), state.LastSeen.Format(time.RFC3339))
+		recData.Hosts = hosts
+		recExtra.Ports = ports
+	}
+	if latest := recExtra.latestTimestamp(); latest.After(state.LastSeen) {
+		state.LastSeen = latest
+	}
+	return state
+}
+
+// exportProject exports the project lairPID for reconciliation. Netblock only
+// imports skip the export unless they need the existing netblocks, and never
+// reconcile hosts.
+func exportProject(c apiClient, o *options, lairPID string) lair.Project {
+	exproject := lair.Project{ID: lairPID}
+	if !o.onlyNetblocks || o.skipExistingNetblocks || o.dedupNetblocksByOverlap {
+		var err error
+		exproject, err = c.ExportProject(lairPID)
+		if err != nil {
+			if err == context.Canceled {
+				fatalf(exitInterrupted, "Fatal: Interrupted\n")
+			}
+			if isAuthError(err) {
+				fatalf(exitAuth, "%s. Error %s\n", authFailed, err.Error())
+			}
+			if isNotFoundError(err) {
+				// The API server has no endpoint for creating projects, so a
+				// missing project has to be created in lair first.
+				log.Fatalf("Fatal: Project %s does not exist, create it in lair before importing. Error %s\n", lairPID, err.Error())
+			}
+			log.Fatalf("Fatal: Unable to export project. Error %s\n", err.Error())
+		}
+	}
+	if o.onlyNetblocks {
+		exproject.Hosts = nil
+	}
+	return exproject
+}
+
+// importAndVerify imports project and, with -verify or
+// -resume-on-partial-server-success, checks that every host arrived. It
+// returns the messages reported by the API server.
+func importAndVerify(ctx context.Context, c apiClient, o *options, project *lair.Project) []string {
+	var serverMessages []string
+	if o.isolateClasses {
+		var failed []string
+		serverMessages, failed = importClasses(ctx, c, &client.DOptions{ForcePorts: o.forcePorts}, project, o.batchSize, o.forcePortsThreshold)
+		if len(failed) > 0 {
+			for _, msg := range serverMessages {
+				log.Printf("Warning: The API server reported: %s\n", msg)
+			}
+			fatalf(exitError, "Fatal: Unable to import %s\n", strings.Join(failed, ", "))
+		}
+	} else {
+		serverMessages = mustImport(ctx, c, &client.DOptions{ForcePorts: o.forcePorts}, project, o.batchSize, o.forcePortsThreshold)
+	}
+
+	if o.verify || o.resumePartial {
+		for attempt := 1; ; attempt++ {
+			imported, err := c.ExportProject(project.ID)
+			if err != nil {
+				if err == context.Canceled {
+					fatalf(exitInterrupted, "Fatal: Interrupted\n")
+				}
+				if isAuthError(err) {
+					fatalf(exitAuth, "%s. Error %s\n", authFailed, err.Error())
+				}
+				log.Fatalf("Fatal: Unable to export project for verification. Error %s\n", err.Error())
+			}
+			err = verifyImport(project, &imported)
+			if err == nil {
+				break
+			}
+			if !o.resumePartial || attempt > o.resumeRetries {
+				log.Fatalf("Fatal: Import verification failed. Error %s\n", err.Error())
+			}
+			missing := missingHosts(project, &imported)
+			ips := []string{}
+			for _, h := range missing {
+				ips = append(ips, h.IPv4)
+			}
+			log.Printf("Warning: %s. Re-importing %d hosts, attempt %d of %d: %s\n", err.Error(), len(missing), attempt, o.resumeRetries, strings.Join(ips, ", "))
+			retry := &lair.Project{ID: project.ID, Tool: project.Tool, Hosts: missing}
+			serverMessages = append(serverMessages, mustImport(ctx, c, &client.DOptions{ForcePorts: o.forcePorts}, retry, o.batchSize, 0)...)
+		}
+		log.Printf("Info: Verified %d hosts are present in lair\n", len(project.Hosts))
+	}
+	return serverMessages
+}
+
+// isNotFoundError reports whether err was caused by the API server not
+// finding the requested project.
+func isNotFoundError(err error) bool {
+	code, ok := errorStatus(err)
+	return ok && code == http.StatusNotFound
+}
+
+// mustParseFiles parses and normalizes filenames, exiting on error.
+func mustParseFiles(filenames []string, opts parseOptions, maxHostnameLength, maxSubdomainLabels int) (*reconng.Data, *extraData) {
+	recData, recExtra, err := parseFiles(filenames, opts)
+	if err != nil {
+		if _, ok := err.(*inputError); ok {
+			fatalf(exitFileIO, "Fatal: Could not read input. Error %s\n", err.Error())
+		}
+		log.Fatalf("Fatal: Could not read recon-ng data. Error %s\n", err.Error())
+	}
+	normalizeData(recData, recExtra, maxHostnameLength, maxSubdomainLabels)
+	return recData, recExtra
+}
+
+// mustImport imports project in batches of at most batchSize hosts and
+// returns any messages reported by the API server. Failures are fatal. Hosts
+// with more than portThreshold services are warned about first.
+func mustImport(ctx context.Context, c apiClient, opts *client.DOptions, project *lair.Project, batchSize, portThreshold int) []string {
+	warnExcessivePorts(opts, project, portThreshold)
+	batches := batchProject(project, batchSize)
+	serverMessages := []string{}
+	for i, batch := range batches {
+		// Once a shutdown is requested no further batches are started.
+		err := ctx.Err()
+		if err == nil {
+			var msg string
+			msg, err = importProject(c, opts, batch)
+			if msg != "" {
+				serverMessages = append(serverMessages, msg)
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if i > 0 {
+			log.Printf("Info: Batches 1-%d of %d were imported successfully\n", i, len(batches))
+		}
+		switch {
+		case err == context.Canceled:
+			fatalf(exitInterrupted, "Fatal: Import interrupted\n")
+		case isAuthError(err):
+			fatalf(exitAuth, "%s. Error %s\n", authFailed, err.Error())
+		case len(batches) == 1:
+			log.Fatalf("Fatal: Unable to import project. Error %s\n", err.Error())
+		}
+		log.Fatalf("Fatal: Unable to import batch %d of %d. Error %s\n", i+1, len(batches), err.Error())
+	}
+	return serverMessages
+}
+
+// importClasses imports the hosts, netblocks, people and credentials of
+// project in separate requests, so that the API server rejecting one class
+// does not prevent the others from being imported. Hosts are imported in
+// batches of at most batchSize. It returns any messages reported by the API
+// server and the classes that failed. Interruptions and authentication
+// failures are fatal.
+func importClasses(ctx context.Context, c apiClient, opts *client.DOptions, project *lair.Project, batchSize, portThreshold int) ([]string, []string) {
+	warnExcessivePorts(opts, project, portThreshold)
+	serverMessages := []string{}
+	failed := []string{}
+	for _, class := range splitClasses(project) {
+		batches := []*lair.Project{class.project}
+		if class.name == "hosts" {
+			batches = batchProject(class.project, batchSize)
+		}
+		imported := true
+		for i, batch := range batches {
+			err := ctx.Err()
+			if err == nil {
+				var msg string
+				msg, err = importProject(c, opts, batch)
+				if msg != "" {
+					serverMessages = append(serverMessages, msg)
+				}
+			}
+			if err == nil {
+				continue
+			}
+			switch {
+			case err == context.Canceled:
+				fatalf(exitInterrupted, "Fatal: Import interrupted\n")
+			case isAuthError(err):
+				fatalf(exitAuth, "%s. Error %s\n", authFailed, err.Error())
+			case len(batches) == 1:
+				log.Printf("Error: Unable to import %s. Error %s\n", class.name, err.Error())
+			default:
+				log.Printf("Error: Unable to import batch %d of %d of %s. Error %s\n", i+1, len(batches), class.name, err.Error())
+			}
+			imported = false
+			break
+		}
+		if imported {
+			log.Printf("Info: Imported %s\n", class.name)
+		} else {
+			failed = append(failed, class.name)
+		}
+	}
+	return serverMessages, failed
+}
+
+// warnExcessivePorts warns about the hosts in project with more than
+// portThreshold services unless ports are forced, since the API server would
+// drop their services.
+func warnExcessivePorts(opts *client.DOptions, project *lair.Project, portThreshold int) {
+	if opts.ForcePorts || portThreshold <= 0 {
+		return
+	}
+	if ips := excessivePorts(project, portThreshold); len(ips) > 0 {
+		log.Printf("Warning: %d hosts have more than %d services and may have their services dropped by the API server, consider -force-ports: %s\n", len(ips), portThreshold, strings.Join(ips, ", "))
+	}
+}
+
+// fatalf logs a fatal message and exits with code.
+func fatalf(code int, format string, v ...interface{}) {
+	log.Printf(format, v...)
+	os.Exit(code)
+}
+
+// isAuthError reports whether err was caused by the API server rejecting the
+// client's credentials.
+func isAuthError(err error) bool {
+	code, ok := errorStatus(err)
+	return ok && (code == http.StatusUnauthorized || code == http.StatusForbidden)
+}
+
+// copyHost returns a copy of h that shares no slices with it.
+func copyHost(h lair.Host) lair.Host {
+	h.Hostnames = append([]string{}, h.Hostnames...)
+	h.Tags = append([]string{}, h.Tags...)
+	h.Notes = append([]lair.Note{}, h.Notes...)
+	h.Services = append([]lair.Service{}, h.Services...)
+	return h
+}
+
+// hostChanged reports whether after, the host before with a recon-ng result
+// merged in, differs from before in its hostnames, status, OS, notes or
+// tags, including the tags in extraTags that are added when it is imported.
+func hostChanged(before, after lair.Host, extraTags []string) bool {
+	if len(after.Hostnames) != len(before.Hostnames) ||
+		after.Status != before.Status || after.StatusMessage != before.StatusMessage ||
+		after.OS != before.OS || after.MAC != before.MAC || len(after.Notes) != len(before.Notes) {
+		return true
+	}
+	for i := range after.Notes {
+		if after.Notes[i].Content != before.Notes[i].Content {
+			return true
+		}
+	}
+	tags := map[string]bool{}
+	for _, t := range before.Tags {
+		tags[t] = true
+	}
+	for _, t := range append(append([]string{}, after.Tags...), extraTags...) {
+		if !tags[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// foldTags lowercases tags and removes those that match a tag in existing, or
+// an earlier tag, ignoring case.
+func foldTags(tags, existing []string) []string {
+	seen := map[string]bool{}
+	for _, t := range existing {
+		seen[strings.ToLower(t)] = true
+	}
+	folded := []string{}
+	for _, t := range tags {
+		t = strings.ToLower(t)
+		if !seen[t] {
+			seen[t] = true
+			folded = append(folded, t)
+		}
+	}
+	return folded
+}
+
+// appendTag appends tag to tags unless it is already present.
+func appendTag(tags []string, tag string) []string {
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
+
+// normalizeEmail trims and lowercases email and checks that it is a valid
+// address. It returns an empty string and false if it is not.
+func normalizeEmail(email string) (string, bool) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", true
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return "", false
+	}
+	return strings.ToLower(addr.Address), true
+}
+
+// hasCommand reports whether commands contains a command with the same tool
+// and arguments as command.
+func hasCommand(commands []lair.Command, command lair.Command) bool {
+	for _, c := range commands {
+		if c.Tool == command.Tool && c.Command == command.Command {
+			return true
+		}
+	}
+	return false
+}
+
+// setNote sets the content of the note with the given title, appending a new
+// note if there is none.
+func setNote(notes []lair.Note, title, content string) []lair.Note {
+	for i := range notes {
+		if notes[i].Title == title {
+			notes[i].Content = content
+			notes[i].LastModifiedBy = tool
+			return notes
+		}
+	}
+	return append(notes, lair.Note{Title: title, Content: content, LastModifiedBy: tool})
+}
+
+// lastSeenNote is the title of the host note recording the most recent
+// recon-ng discovery time.
+const lastSeenNote = "recon-ng last seen"
+
+// setLastSeen records t in the last seen note unless the note already holds a
+// later time.
+func setLastSeen(notes []lair.Note, t time.Time) []lair.Note {
+	for _, n := range notes {
+		if n.Title != lastSeenNote {
+			continue
+		}
+		if seen, err := time.Parse(time.RFC3339, strings.TrimSpace(n.Content)); err == nil && !t.After(seen) {
+			return notes
+		}
+	}
+	return setNote(notes, lastSeenNote, t.UTC().Format(time.RFC3339))
+}
+
+// hostByHostname returns the index of the only host in hosts that has name
+// as one of its hostnames. To avoid merging unrelated hosts, names without a
+// domain, generic names and names shared by more than one host never match.
+func hostByHostname(hosts []lair.Host, name string) (int, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if !strings.Contains(name, ".") || strings.HasPrefix(name, "localhost.") {
+		return 0, false
+	}
+	match := -1
+	for i, h := range hosts {
+		for _, hn := range h.Hostnames {
+			if strings.EqualFold(strings.TrimSuffix(hn, "."), name) {
+				if match != -1 && match != i {
+					return 0, false
+				}
+				match = i
+			}
+		}
+	}
+	return match, match != -1
+}
+
+// addNoteValue adds value to the comma separated list held in the note with
+// the given title, creating the note if needed.
+func addNoteValue(notes []lair.Note, title, value string) []lair.Note {
+	for _, n := range notes {
+		if n.Title != title {
+			continue
+		}
+		values := strings.Split(n.Content, ", ")
+		for _, v := range values {
+			if v == value {
+				return notes
+			}
+		}
+		return setNote(notes, title, strings.Join(append(values, value), ", "))
+	}
+	return setNote(notes, title, value)
+}
+
+// osGuess describes an OS guess for a host note.
+func osGuess(guess lair.OS, module string) string {
+	desc := fmt.Sprintf("%s (confidence %d", guess.Fingerprint, guess.Weight)
+	if module != "" {
+		desc += "; source " + module
+	}
+	return desc + ")"
+}
+
+// sortedIPs returns the IPs in notFound in sorted order.
+func sortedIPs(notFound map[string][]reconng.Host) []string {
+	ips := []string{}
+	for ip := range notFound {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// resultHostnames returns the unique hostnames found in results.
+func resultHostnames(results []reconng.Host) []string {
+	hostnames := []string{}
+	for _, r := range results {
+		hostnames = appendHostnames(hostnames, r.Name)
+	}
+	return hostnames
+}
+
+// hostnameDivergences returns a message for every lair host whose existing
+// hostnames do not overlap at all with the hostnames recon-ng reports for the
+// same IP. This can indicate stale DNS or a shared IP and is worth a look, but
+// does not change what is imported.
+func hostnameDivergences(hosts []lair.Host, results []reconng.Host) []string {
+	byIP := map[string][]reconng.Host{}
+	for _, r := range results {
+		byIP[r.IPAddress] = append(byIP[r.IPAddress], r)
+	}
+	msgs := []string{}
+	for _, h := range hosts {
+		if len(h.Hostnames) == 0 {
+			continue
+		}
+		names := resultHostnames(byIP[h.IPv4])
+		if len(names) == 0 {
+			continue
+		}
+		if len(appendHostnames(append([]string{}, h.Hostnames...), names...)) == len(h.Hostnames)+len(names) {
+			msgs = append(msgs, fmt.Sprintf("recon-ng reports %s as %s but lair has %s",
+				h.IPv4, strings.Join(names, ", "), strings.Join(h.Hostnames, ", ")))
+		}
+	}
+	return msgs
+}
+
+// appendHostnames appends each name in names to hostnames unless it is empty
+// or already present. The comparison is case-insensitive and the casing of a
+// hostname that is already stored is preserved.
+func appendHostnames(hostnames []string, names ...string) []string {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, h := range hostnames {
+			if strings.EqualFold(h, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			hostnames = append(hostnames, name)
+		}
+	}
+	return hostnames
+}
+
+// mergeDuplicateHosts merges hosts that share an IPv4 address into the first
+// of them, combining their hostnames, services, tags and notes. It returns the
+// remaining hosts and the number of hosts merged for each IP.
+func mergeDuplicateHosts(hosts []lair.Host) ([]lair.Host, map[string]int) {
+	merged := []lair.Host{}
+	index := map[string]int{}
+	dups := map[string]int{}
+	for _, h := range hosts {
+		i, ok := index[h.IPv4]
+		if !ok || h.IPv4 == "" {
+			index[h.IPv4] = len(merged)
+			merged = append(merged, h)
+			continue
+		}
+		dups[h.IPv4]++
+		m := &merged[i]
+		m.Hostnames = appendHostnames(m.Hostnames, h.Hostnames...)
+		m.Services = appendServices(m.Services, h.Services...)
+		for _, t := range h.Tags {
+			m.Tags = appendTag(m.Tags, t)
+		}
+		for _, n := range h.Notes {
+			if !hasNote(m.Notes, n.Title) {
+				m.Notes = append(m.Notes, n)
+			}
+		}
+		if h.OS.Fingerprint != "" && (m.OS.Fingerprint == "" || h.OS.Weight > m.OS.Weight) {
+			m.OS = h.OS
+		}
+		m.IsFlagged = m.IsFlagged || h.IsFlagged
+	}
+	return merged, dups
+}
+
+// hasNote reports whether notes has a note with the given title.
+func hasNote(notes []lair.Note, title string) bool {
+	for _, n := range notes {
+		if n.Title == title {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNoteValue reports whether notes has a note with the given title and
+// content.
+func hasNoteValue(notes []lair.Note, title, content string) bool {
+	for _, n := range notes {
+		if n.Title == title && n.Content == content {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]int) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// missingHosts returns the hosts in project that are missing from imported,
+// or are missing some of their hostnames.
+func missingHosts(project, imported *lair.Project) []lair.Host {
+	hosts := map[string]lair.Host{}
+	for _, h := range imported.Hosts {
+		hosts[h.IPv4] = h
+	}
+	missing := []lair.Host{}
+	for _, h := range project.Hosts {
+		ih, ok := hosts[h.IPv4]
+		if !ok || len(appendHostnames(append([]string{}, ih.Hostnames...), h.Hostnames...)) > len(ih.Hostnames) {
+			missing = append(missing, h)
+		}
+	}
+	return missing
+}
+
+// verifyImport confirms that every host and hostname in project is present in
+// imported, which should be a fresh export of the project taken after the
+// import. The server can accept an import while silently dropping data, so
+// this is the only way to know for certain that it landed.
+func verifyImport(project, imported *lair.Project) error {
+	hosts := map[string]lair.Host{}
+	for _, h := range imported.Hosts {
+		hosts[h.IPv4] = h
+	}
+	missingHosts := []string{}
+	missingHostnames := 0
+	for _, h := range project.Hosts {
+		ih, ok := hosts[h.IPv4]
+		if !ok {
+			missingHosts = append(missingHosts, h.IPv4)
+			continue
+		}
+		missingHostnames += len(appendHostnames(ih.Hostnames, h.Hostnames...)) - len(ih.Hostnames)
+	}
+	if len(missingHosts) > 0 || missingHostnames > 0 {
+		return fmt.Errorf("%d of %d hosts and %d hostnames are missing from the project after import: %s",
+			len(missingHosts), len(project.Hosts), missingHostnames, strings.Join(missingHosts, ", "))
+	}
+	return nil
+}
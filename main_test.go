@@ -0,0 +1,885 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lair "github.com/lair-framework/go-lair"
+)
+
+// Most tests in this file run the drone end to end against mockLair. The
+// drone exits the process, so it is run as a child process of the test
+// binary, which runs main when droneHelperEnv is set.
+const droneHelperEnv = "DRONE_RECON_NG_TEST_HELPER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(droneHelperEnv) != "" {
+		args := []string{}
+		if err := json.Unmarshal([]byte(os.Getenv(droneHelperEnv)), &args); err != nil {
+			panic(err)
+		}
+		os.Args = append([]string{"drone-recon-ng"}, args...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// mockLair is an httptest.Server implementing the lair API endpoints the
+// drone calls. It exports project and records every project imported.
+type mockLair struct {
+	*httptest.Server
+	t *testing.T
+	// id is the id of the project, which does not change.
+	id string
+
+	// mu guards the fields below, which the handler reads while tests
+	// change them between runs.
+	mu      sync.Mutex
+	project lair.Project
+	// status, when set, is returned for every request instead of handling
+	// it.
+	status  int
+	imports []lair.Project
+	queries []string
+}
+
+// newMockLair starts a mock API server exporting project.
+func newMockLair(t *testing.T, project lair.Project) *mockLair {
+	m := &mockLair{t: t, id: project.ID, project: project}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockLair) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	status, project := m.status, m.project
+	m.mu.Unlock()
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/api/projects" && r.Method == "GET" {
+		json.NewEncoder(w).Encode([]lair.Project{project})
+		return
+	}
+	if r.URL.Path != "/api/projects/"+m.id {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(project)
+	case "PATCH":
+		project = lair.Project{}
+		if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+			m.t.Errorf("mock lair: could not decode import: %s", err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.mu.Lock()
+		m.imports = append(m.imports, project)
+		m.queries = append(m.queries, r.URL.RawQuery)
+		m.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"Status": "Ok", "Message": ""})
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setStatus makes the server return status for every request.
+func (m *mockLair) setStatus(status int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = status
+}
+
+// update calls f to change the exported project.
+func (m *mockLair) update(f func(project *lair.Project)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f(&m.project)
+}
+
+// exported returns the exported project.
+func (m *mockLair) exported() lair.Project {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.project
+}
+
+// imported returns the projects imported so far.
+func (m *mockLair) imported() []lair.Project {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]lair.Project{}, m.imports...)
+}
+
+// lastImport returns the only project imported, failing the test if there
+// was not exactly one import.
+func (m *mockLair) lastImport() lair.Project {
+	m.t.Helper()
+	imports := m.imported()
+	if len(imports) != 1 {
+		m.t.Fatalf("got %d imports, want 1", len(imports))
+	}
+	return imports[0]
+}
+
+// droneResult is the outcome of a drone run.
+type droneResult struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+// runDrone runs the drone against m with args, which should end with the
+// input files and may use writeInput to create them.
+func runDrone(t *testing.T, m *mockLair, args ...string) droneResult {
+	t.Helper()
+	server := strings.Replace(m.URL, "http://", "http://user:pass@", 1)
+	return runDroneEnv(t, []string{"LAIR_API_SERVER=" + server, "LAIR_ID=" + m.id}, args...)
+}
+
+// runDroneEnv runs the drone with args and the environment variables env.
+func runDroneEnv(t *testing.T, env []string, args ...string) droneResult {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Env = append(os.Environ(), droneHelperEnv+"="+string(encoded))
+	cmd.Env = append(cmd.Env, env...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	err = cmd.Run()
+	r := droneResult{stdout: stdout.String(), stderr: stderr.String()}
+	if exit, ok := err.(*exec.ExitError); ok {
+		r.code = exit.ExitCode()
+	} else if err != nil {
+		t.Fatalf("could not run the drone: %s", err.Error())
+	}
+	return r
+}
+
+// findHost returns the host with ip in project.
+func findHost(t *testing.T, project lair.Project, ip string) lair.Host {
+	t.Helper()
+	for _, h := range project.Hosts {
+		if h.IPv4 == ip {
+			return h
+		}
+	}
+	t.Fatalf("host %s was not imported, got %v", ip, project.Hosts)
+	return lair.Host{}
+}
+
+func TestImportByProjectName(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Name: "Acme External", Hosts: []lair.Host{{IPv4: "10.0.0.1"}}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}]}`)
+	server := strings.Replace(m.URL, "http://", "http://user:pass@", 1)
+	r := runDroneEnv(t, []string{"LAIR_API_SERVER=" + server, "LAIR_ID="}, "-project-name", "acme external", input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	if !strings.Contains(r.stderr, "Using project pid for acme external") {
+		t.Errorf("stderr does not report the resolved project:\n%s", r.stderr)
+	}
+	if got := m.lastImport().ID; got != "pid" {
+		t.Errorf("imported into %q, want pid", got)
+	}
+}
+
+func TestImportExistingHost(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{
+		{IPv4: "10.0.0.1", Hostnames: []string{"a.example.com"}, Status: "lair-grey"},
+	}})
+	input := writeInput(t, "recon.json", `{"hosts": [
+		{"host": "b.example.com", "ip_address": "10.0.0.1"},
+		{"host": "c.example.com", "ip_address": "10.9.9.9"}
+	]}`)
+	r := runDrone(t, m, "-tags", "recon", input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	imported := m.lastImport()
+	if imported.ID != "pid" || imported.Tool != tool {
+		t.Errorf("imported project %q by %q, want pid by %q", imported.ID, imported.Tool, tool)
+	}
+	if len(imported.Hosts) != 1 {
+		t.Fatalf("imported %d hosts, want only the existing host", len(imported.Hosts))
+	}
+	h := imported.Hosts[0]
+	if want := []string{"a.example.com", "b.example.com"}; strings.Join(h.Hostnames, ",") != strings.Join(want, ",") {
+		t.Errorf("hostnames = %v, want %v", h.Hostnames, want)
+	}
+	if strings.Join(h.Tags, ",") != "recon" || h.LastModifiedBy != tool {
+		t.Errorf("tags = %v, last modified by %q, want [recon] by %q", h.Tags, h.LastModifiedBy, tool)
+	}
+	if strings.TrimSpace(r.stdout) != "10.9.9.9" {
+		t.Errorf("hosts not found in lair = %q, want 10.9.9.9", r.stdout)
+	}
+}
+
+func TestImportForceHosts(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid"})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "c.example.com", "ip_address": "10.9.9.9"}]}`)
+	if r := runDrone(t, m, "-force-hosts", input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	h := findHost(t, m.lastImport(), "10.9.9.9")
+	if strings.Join(h.Hostnames, ",") != "c.example.com" || h.Status != statusGrey {
+		t.Errorf("new host = %+v, want c.example.com with status %s", h, statusGrey)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}]}`)
+
+	m := newMockLair(t, lair.Project{ID: "pid"})
+	m.setStatus(http.StatusUnauthorized)
+	if r := runDrone(t, m, input); r.code != exitAuth {
+		t.Errorf("unauthorized: exit code %d, want %d, stderr:\n%s", r.code, exitAuth, r.stderr)
+	}
+
+	m = newMockLair(t, lair.Project{ID: "other"})
+	r := runDroneEnv(t, []string{"LAIR_API_SERVER=" + strings.Replace(m.URL, "http://", "http://user:pass@", 1), "LAIR_ID=pid"}, input)
+	if r.code != exitError || !strings.Contains(r.stderr, "does not exist") {
+		t.Errorf("missing project: exit code %d, want %d, stderr:\n%s", r.code, exitError, r.stderr)
+	}
+
+	// The project id and port of a server that is down hold status-like
+	// digits, which must not be mistaken for the status code.
+	m = newMockLair(t, lair.Project{ID: "5a4031fe4040"})
+	m.Close()
+	r = runDrone(t, m, input)
+	if r.code != exitError || strings.Contains(r.stderr, "Authentication failed") || strings.Contains(r.stderr, "does not exist") {
+		t.Errorf("server down: exit code %d, want %d, stderr:\n%s", r.code, exitError, r.stderr)
+	}
+}
+
+func TestAppendHostnamesKeepsStoredCase(t *testing.T) {
+	got := appendHostnames([]string{"Example.com"}, "example.com", "EXAMPLE.COM", "", "www.example.com")
+	if want := []string{"Example.com", "www.example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("appendHostnames() = %v, want %v", got, want)
+	}
+}
+
+func TestImportHostnameCaseDiffers(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1", Hostnames: []string{"Example.com"}}}})
+	input := writeInput(t, "recon.json", `{"hosts": [
+		{"host": "example.com", "ip_address": "10.0.0.1"},
+		{"host": "new.example.com", "ip_address": "10.0.0.1"}
+	]}`)
+	if r := runDrone(t, m, input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	h := findHost(t, m.lastImport(), "10.0.0.1")
+	if want := []string{"Example.com", "new.example.com"}; !reflect.DeepEqual(h.Hostnames, want) {
+		t.Errorf("hostnames = %v, want %v", h.Hostnames, want)
+	}
+}
+
+func TestImportHostSplitAcrossFiles(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "1.2.3.4"}}})
+	first := writeInput(t, "out.1.json", `{"hosts": [{"host": "a.com", "ip_address": "1.2.3.4"}]}`)
+	second := writeInput(t, "out.2.json", `{"hosts": [{"host": "b.com", "ip_address": "1.2.3.4"}]}`)
+	if r := runDrone(t, m, "pid", first, second); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	imported := m.lastImport()
+	if len(imported.Hosts) != 1 {
+		t.Fatalf("imported %d hosts, want 1", len(imported.Hosts))
+	}
+	if want := []string{"a.com", "b.com"}; !reflect.DeepEqual(imported.Hosts[0].Hostnames, want) {
+		t.Errorf("hostnames = %v, want %v", imported.Hosts[0].Hostnames, want)
+	}
+}
+
+func TestImportKeepsExistingServices(t *testing.T) {
+	nmap := lair.Service{Port: 443, Protocol: "tcp", Service: "https", LastModifiedBy: "nmap"}
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1", Services: []lair.Service{nmap}}}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}], "ports": [{"ip_address": "10.0.0.1", "port": "80", "protocol": "tcp"}]}`)
+	if r := runDrone(t, m, input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	h := findHost(t, m.lastImport(), "10.0.0.1")
+	if len(h.Services) != 2 || h.Services[0].Service != nmap.Service || h.Services[1].Port != 80 {
+		t.Errorf("services = %+v, want 443 from nmap followed by 80", h.Services)
+	}
+}
+
+func TestEmptyInputFile(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid"})
+	for name, content := range map[string]string{"empty": "", "whitespace": "  \n\t\n  \n"} {
+		input := writeInput(t, "recon.json", content)
+		r := runDrone(t, m, input)
+		if r.code != exitFileIO || !strings.Contains(r.stderr, "is empty") {
+			t.Errorf("%s file: exit code %d, want %d, stderr:\n%s", name, r.code, exitFileIO, r.stderr)
+		}
+	}
+	if imports := m.imported(); len(imports) != 0 {
+		t.Errorf("got %d imports of empty input, want none", len(imports))
+	}
+}
+
+func TestRepeatedImportRecordsCommandOnce(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1"}}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}]}`)
+	for i := 0; i < 2; i++ {
+		if r := runDrone(t, m, input); r.code != 0 {
+			t.Fatalf("import %d: exit code %d, stderr:\n%s", i+1, r.code, r.stderr)
+		}
+		// The API server appends the imported commands to the project.
+		imports := m.imported()
+		m.update(func(p *lair.Project) {
+			p.Commands = append(p.Commands, imports[len(imports)-1].Commands...)
+		})
+	}
+	if commands := m.exported().Commands; len(commands) != 1 || commands[0].Tool != tool {
+		t.Errorf("commands after two imports = %+v, want one %s command", commands, tool)
+	}
+}
+
+func TestImportRecordsCollectionDate(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1"}}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}]}`)
+	for _, date := range []string{"2020-01-02T03:04:05Z", "2020-02-03T04:05:06Z", ""} {
+		args := []string{input}
+		if date != "" {
+			args = append([]string{"-date", date}, args...)
+		}
+		if r := runDrone(t, m, args...); r.code != 0 {
+			t.Fatalf("-date %q: exit code %d, stderr:\n%s", date, r.code, r.stderr)
+		}
+		imports := m.imported()
+		m.update(func(p *lair.Project) {
+			p.Commands = append(p.Commands, imports[len(imports)-1].Commands...)
+			p.Notes = append(p.Notes, imports[len(imports)-1].Notes...)
+		})
+	}
+	exported := m.exported()
+	if len(exported.Commands) != 1 || exported.Commands[0] != (lair.Command{Tool: tool}) {
+		t.Errorf("commands = %+v, want one %s command", exported.Commands, tool)
+	}
+	dates := []string{}
+	for _, n := range exported.Notes {
+		if n.Title == "recon-ng collected" {
+			dates = append(dates, n.Content)
+		}
+	}
+	if len(dates) != 3 || dates[0] != "2020-01-02T03:04:05Z" || dates[1] != "2020-02-03T04:05:06Z" {
+		t.Fatalf("collection dates = %v, want both -date values and the current time", dates)
+	}
+	if now, err := time.Parse(time.RFC3339, dates[2]); err != nil || time.Since(now) > time.Minute {
+		t.Errorf("default collection date = %q, want the current time", dates[2])
+	}
+}
+
+func TestHasCommand(t *testing.T) {
+	commands := []lair.Command{{Tool: "nmap", Command: "nmap -sV"}, {Tool: tool}}
+	if !hasCommand(commands, lair.Command{Tool: tool}) {
+		t.Error("hasCommand did not find the recon-ng command")
+	}
+	if hasCommand(commands, lair.Command{Tool: tool, Command: "recon-ng -r import.rc"}) {
+		t.Error("hasCommand matched a command with different arguments")
+	}
+}
+
+func TestImportMultipleIPsInOneField(t *testing.T) {
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "1.2.3.4,5.6.7.8"}]}`)
+
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "1.2.3.4"}, {IPv4: "5.6.7.8"}}})
+	if r := runDrone(t, m, input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	for _, ip := range []string{"1.2.3.4", "5.6.7.8"} {
+		if h := findHost(t, m.lastImport(), ip); strings.Join(h.Hostnames, ",") != "a.example.com" {
+			t.Errorf("hostnames of %s = %v, want [a.example.com]", ip, h.Hostnames)
+		}
+	}
+
+	m = newMockLair(t, lair.Project{ID: "pid"})
+	r := runDrone(t, m, input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	got := strings.Fields(r.stdout)
+	sort.Strings(got)
+	if strings.Join(got, " ") != "1.2.3.4 5.6.7.8" {
+		t.Errorf("hosts not found in lair = %q, want 1.2.3.4 and 5.6.7.8", r.stdout)
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+		ok    bool
+	}{
+		{"Foo@Example.COM", "foo@example.com", true},
+		{"  bar@example.com\n", "bar@example.com", true},
+		{"notanemail", "", false},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		if got, ok := normalizeEmail(tt.email); got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeEmail(%q) = %q, %v, want %q, %v", tt.email, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestImportDropsInvalidEmails(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid"})
+	input := writeInput(t, "recon.json", `{"contacts": [
+		{"first_name": "Foo", "email": "Foo@Example.COM"},
+		{"first_name": "Bar", "email": "notanemail"}
+	]}`)
+	r := runDrone(t, m, input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	people := m.lastImport().People
+	if len(people) != 2 {
+		t.Fatalf("imported %d people, want 2", len(people))
+	}
+	if p := people[0]; p.PrincipalName != "foo@example.com" || strings.Join(p.Emails, ",") != "foo@example.com" {
+		t.Errorf("person = %+v, want foo@example.com", p)
+	}
+	if p := people[1]; p.FirstName != "Bar" || p.PrincipalName != "" || len(p.Emails) != 0 {
+		t.Errorf("person = %+v, want Bar without an email", p)
+	}
+	if !strings.Contains(r.stderr, "Dropped 1 invalid contact emails") {
+		t.Errorf("stderr does not count the invalid email:\n%s", r.stderr)
+	}
+}
+
+func TestSortOutputIsStable(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid"})
+	input := writeInput(t, "recon.json", `{"hosts": [
+		{"host": "b.example.com", "ip_address": "10.0.0.10"},
+		{"host": "a.example.com", "ip_address": "10.0.0.10"},
+		{"host": "c.example.com", "ip_address": "10.0.0.9"},
+		{"host": "d.example.com", "ip_address": "10.0.0.1"}
+	], "contacts": [
+		{"first_name": "Zed", "email": "zed@example.com"},
+		{"first_name": "Amy", "email": "amy@example.com"}
+	]}`)
+	// The collection time defaults to the time of the run, so it is pinned
+	// for the runs to be comparable.
+	for i := 0; i < 3; i++ {
+		if r := runDrone(t, m, "-force-hosts", "-sort-output", "-date", "2020-01-02T03:04:05Z", input); r.code != 0 {
+			t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+		}
+	}
+	imports := m.imported()
+	for _, p := range imports[1:] {
+		if !reflect.DeepEqual(p, imports[0]) {
+			t.Fatalf("payloads differ across runs:\n%+v\n%+v", imports[0], p)
+		}
+	}
+	ips := []string{}
+	for _, h := range imports[0].Hosts {
+		ips = append(ips, h.IPv4)
+	}
+	if strings.Join(ips, " ") != "10.0.0.1 10.0.0.9 10.0.0.10" {
+		t.Errorf("host order = %v, want numeric IPv4 order", ips)
+	}
+	if h := findHost(t, imports[0], "10.0.0.10"); strings.Join(h.Hostnames, ",") != "a.example.com,b.example.com" {
+		t.Errorf("hostnames = %v, want them sorted", h.Hostnames)
+	}
+	if p := imports[0].People; len(p) != 2 || p[0].PrincipalName != "amy@example.com" {
+		t.Errorf("people = %+v, want them sorted by email", p)
+	}
+}
+
+func TestSortOutputKeepsExportedSnapshot(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1", Hostnames: []string{"b.example.com", "y.example.com", "z.example.com"}}}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}]}`)
+	r := runDrone(t, m, "-sort-output", "-compare-only", input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	if got := strings.TrimSpace(r.stdout); got != "host-hostnames 10.0.0.1 a.example.com" {
+		t.Errorf("changes = %q, want only a.example.com added to 10.0.0.1", got)
+	}
+}
+
+func TestImportKeepsExistingOSAsNote(t *testing.T) {
+	nmap := lair.OS{Tool: "nmap", Weight: 90, Fingerprint: "Linux 3.x"}
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1", OS: nmap}, {IPv4: "10.0.0.2"}}})
+	input := writeInput(t, "recon.json", `{"hosts": [
+		{"host": "a.example.com", "ip_address": "10.0.0.1", "os": "Windows 10", "os_confidence": "80", "module": "recon/hosts-hosts/shodan_ip"},
+		{"host": "b.example.com", "ip_address": "10.0.0.2", "os": "Windows 10", "os_confidence": 80}
+	]}`)
+	if r := runDrone(t, m, input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	h := findHost(t, m.lastImport(), "10.0.0.1")
+	if h.OS != nmap {
+		t.Errorf("OS = %+v, want the existing %+v", h.OS, nmap)
+	}
+	want := "Windows 10 (confidence 80; source recon/hosts-hosts/shodan_ip)"
+	if len(h.Notes) != 1 || h.Notes[0].Title != "recon-ng OS guess" || h.Notes[0].Content != want {
+		t.Errorf("notes = %+v, want a recon-ng OS guess note of %q", h.Notes, want)
+	}
+	h = findHost(t, m.lastImport(), "10.0.0.2")
+	if h.OS.Fingerprint != "Windows 10" || h.OS.Weight != 80 || len(h.Notes) != 0 {
+		t.Errorf("host without an OS = %+v, want the recon-ng guess set without a note", h)
+	}
+}
+
+func TestImportUpdatesLastSeen(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1"}}})
+	lastSeen := func(timestamp string) string {
+		t.Helper()
+		input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1", "timestamp": "`+timestamp+`"}]}`)
+		before := len(m.imported())
+		if r := runDrone(t, m, input); r.code != 0 {
+			t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+		}
+		// The next export holds the host as it was imported.
+		h := findHost(t, m.imported()[before], "10.0.0.1")
+		m.update(func(p *lair.Project) { p.Hosts = []lair.Host{h} })
+		for _, n := range h.Notes {
+			if n.Title == lastSeenNote {
+				return n.Content
+			}
+		}
+		return ""
+	}
+	if got := lastSeen("2020-01-02 03:04:05"); got != "2020-01-02T03:04:05Z" {
+		t.Errorf("last seen after the first import = %q", got)
+	}
+	if got := lastSeen("2020-06-01T00:00:00Z"); got != "2020-06-01T00:00:00Z" {
+		t.Errorf("last seen after a newer import = %q, want the newer time", got)
+	}
+	if got := lastSeen("2019-01-01T00:00:00Z"); got != "2020-06-01T00:00:00Z" {
+		t.Errorf("last seen after an older import = %q, want it unchanged", got)
+	}
+}
+
+func TestMergeDuplicateHosts(t *testing.T) {
+	hosts := []lair.Host{
+		{
+			IPv4:      "10.0.0.1",
+			Hostnames: []string{"a.example.com"},
+			Tags:      []string{"external"},
+			Services:  []lair.Service{{Port: 443, Protocol: "tcp"}},
+		},
+		{IPv4: "10.0.0.2"},
+		{
+			IPv4:      "10.0.0.1",
+			Hostnames: []string{"A.example.com", "b.example.com"},
+			Tags:      []string{"external", "web"},
+			Services:  []lair.Service{{Port: 443, Protocol: "tcp"}, {Port: 80, Protocol: "tcp"}},
+			OS:        lair.OS{Fingerprint: "Linux", Weight: 50},
+			IsFlagged: true,
+		},
+	}
+	merged, dups := mergeDuplicateHosts(hosts)
+	if len(merged) != 2 || merged[0].IPv4 != "10.0.0.1" || merged[1].IPv4 != "10.0.0.2" {
+		t.Fatalf("merged = %v, want 10.0.0.1 and 10.0.0.2", merged)
+	}
+	if want := map[string]int{"10.0.0.1": 1}; !reflect.DeepEqual(dups, want) {
+		t.Errorf("dups = %v, want %v", dups, want)
+	}
+	h := merged[0]
+	if strings.Join(h.Hostnames, ",") != "a.example.com,b.example.com" || strings.Join(h.Tags, ",") != "external,web" {
+		t.Errorf("hostnames = %v, tags = %v, want both hosts' combined", h.Hostnames, h.Tags)
+	}
+	if len(h.Services) != 2 || h.OS.Fingerprint != "Linux" || !h.IsFlagged {
+		t.Errorf("merged host = %+v, want 443 and 80, the Linux OS and flagged", h)
+	}
+}
+
+func TestImportDeduplicatesExport(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{
+		{IPv4: "10.0.0.1", Hostnames: []string{"a.example.com"}},
+		{IPv4: "10.0.0.1", Hostnames: []string{"b.example.com"}},
+	}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "c.example.com", "ip_address": "10.0.0.1"}]}`)
+	r := runDrone(t, m, "-deduplicate-across-export", input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	imported := m.lastImport()
+	if len(imported.Hosts) != 1 {
+		t.Fatalf("imported %d hosts for 10.0.0.1, want one", len(imported.Hosts))
+	}
+	if got := strings.Join(imported.Hosts[0].Hostnames, ","); got != "a.example.com,b.example.com,c.example.com" {
+		t.Errorf("hostnames = %s, want both duplicates' and the recon-ng hostname", got)
+	}
+	if !strings.Contains(r.stderr, "Merging 1 duplicate lair hosts for 10.0.0.1") {
+		t.Errorf("the merge was not logged:\n%s", r.stderr)
+	}
+}
+
+func TestImportKeepsExistingNotes(t *testing.T) {
+	existing := lair.Note{Title: "nmap", Content: "scanned", LastModifiedBy: "nmap"}
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1", Notes: []lair.Note{existing}}}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1", "asn": "AS64500"}]}`)
+	if r := runDrone(t, m, "-notes-from-field", "asn", input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	h := findHost(t, m.lastImport(), "10.0.0.1")
+	if len(h.Notes) != 2 || h.Notes[0] != existing {
+		t.Fatalf("notes = %+v, want the existing note followed by the new one", h.Notes)
+	}
+	if h.Notes[1].Title != "recon-ng asn" || h.Notes[1].Content != "AS64500" {
+		t.Errorf("new note = %+v, want recon-ng asn AS64500", h.Notes[1])
+	}
+}
+
+func TestTagFromEmailDomain(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid"})
+	input := writeInput(t, "recon.json", `{"contacts": [
+		{"first_name": "Foo", "email": "foo@Example.COM"},
+		{"first_name": "Bar", "email": "bar@other.example.org"},
+		{"first_name": "Baz", "email": "notanemail"}
+	]}`)
+	if r := runDrone(t, m, "-tag-from-email-domain", input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	want := map[string]string{"Foo": "org:example.com", "Bar": "org:other.example.org"}
+	for _, p := range m.lastImport().People {
+		orgs := []string{}
+		for _, g := range p.Groups {
+			if strings.HasPrefix(g, "org:") {
+				orgs = append(orgs, g)
+			}
+		}
+		if got := strings.Join(orgs, ","); got != want[p.FirstName] {
+			t.Errorf("org tags of %s = %q, want %q", p.FirstName, got, want[p.FirstName])
+		}
+	}
+}
+
+func TestImportXMLWorkspace(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "acme", Hosts: []lair.Host{{IPv4: "10.0.0.1"}}})
+	input := writeInput(t, "recon.xml", `<?xml version="1.0"?>
+<export>
+	<workspace>acme</workspace>
+	<hosts>
+		<row><host>a.example.com</host><ip_address>10.0.0.1</ip_address></row>
+	</hosts>
+</export>`)
+	server := strings.Replace(m.URL, "http://", "http://user:pass@", 1)
+	r := runDroneEnv(t, []string{"LAIR_API_SERVER=" + server, "LAIR_ID="}, "-id-from-workspace", "-group-tag", input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	if h := findHost(t, m.lastImport(), "10.0.0.1"); strings.Join(h.Tags, ",") != "workspace:acme" {
+		t.Errorf("tags = %v, want [workspace:acme]", h.Tags)
+	}
+}
+
+func TestImportFuzzyHostnameMatch(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{
+		{IPv4: "10.0.0.1", Hostnames: []string{"www.example.com"}},
+		{IPv4: "10.0.0.2", Hostnames: []string{"mail.example.com"}},
+	}})
+	input := writeInput(t, "recon.json", `{"hosts": [
+		{"host": "ww.example.com", "ip_address": "10.9.9.1"},
+		{"host": "ml.exampl.com", "ip_address": "10.9.9.2"}
+	]}`)
+	r := runDrone(t, m, "-fuzzy-hostname-match", "2", input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	h := findHost(t, m.lastImport(), "10.0.0.1")
+	if strings.Join(h.Hostnames, ",") != "www.example.com,ww.example.com" {
+		t.Errorf("hostnames = %v, want ww.example.com merged at distance 1", h.Hostnames)
+	}
+	if !strings.Contains(r.stderr, "Fuzzy merging 10.9.9.1 (ww.example.com) onto lair host 10.0.0.1 (www.example.com) at distance 1") {
+		t.Errorf("the fuzzy match was not logged:\n%s", r.stderr)
+	}
+	if strings.TrimSpace(r.stdout) != "10.9.9.2" {
+		t.Errorf("hosts not found in lair = %q, want 10.9.9.2 at distance 3", r.stdout)
+	}
+}
+
+func TestFoldTags(t *testing.T) {
+	got := foldTags([]string{"Prod", "prod", "Web", "DMZ"}, []string{"dmz"})
+	if want := []string{"prod", "web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("foldTags = %v, want %v", got, want)
+	}
+}
+
+func TestImportIgnoreCaseTags(t *testing.T) {
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}]}`)
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-tags", "prod,Web"}, "prod,Web"},
+		// The API server merges the tags sent with the host's, so a tag
+		// that only differs in case from the existing Prod is not sent.
+		{[]string{"-ignore-case-tags", "-tags", "prod,Web"}, "web"},
+	} {
+		m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1", Tags: []string{"Prod"}}}})
+		if r := runDrone(t, m, append(tt.args, input)...); r.code != 0 {
+			t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+		}
+		if got := strings.Join(findHost(t, m.lastImport(), "10.0.0.1").Tags, ","); got != tt.want {
+			t.Errorf("%v: tags = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestImportLinksHostsToNetblocks(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.5"}, {IPv4: "192.168.1.5"}}})
+	input := writeInput(t, "recon.json", `{
+		"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.5"}, {"host": "b.example.com", "ip_address": "192.168.1.5"}],
+		"netblocks": [{"netblock": "10.0.0.0/24"}]
+	}`)
+	if r := runDrone(t, m, "-link-netblocks", input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	h := findHost(t, m.lastImport(), "10.0.0.5")
+	if len(h.Notes) != 1 || h.Notes[0].Title != "recon-ng netblock" || h.Notes[0].Content != "10.0.0.0/24" {
+		t.Errorf("notes = %+v, want a recon-ng netblock note of 10.0.0.0/24", h.Notes)
+	}
+	if h := findHost(t, m.lastImport(), "192.168.1.5"); len(h.Notes) != 0 {
+		t.Errorf("notes of a host outside every netblock = %+v, want none", h.Notes)
+	}
+}
+
+func TestImportUnchangedHostIsNotStamped(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{
+		{IPv4: "10.0.0.1", Hostnames: []string{"a.example.com"}, LastModifiedBy: "nmap"},
+		{IPv4: "10.0.0.2", Hostnames: []string{"b.example.com"}, LastModifiedBy: "nmap"},
+	}})
+	input := writeInput(t, "recon.json", `{"hosts": [
+		{"host": "A.example.com", "ip_address": "10.0.0.1"},
+		{"host": "c.example.com", "ip_address": "10.0.0.2"}
+	]}`)
+	if r := runDrone(t, m, input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	if h := findHost(t, m.lastImport(), "10.0.0.1"); h.LastModifiedBy != "nmap" || strings.Join(h.Hostnames, ",") != "a.example.com" {
+		t.Errorf("unchanged host = %+v, want it left as modified by nmap", h)
+	}
+	if h := findHost(t, m.lastImport(), "10.0.0.2"); h.LastModifiedBy != tool {
+		t.Errorf("host with a new hostname was last modified by %q, want %q", h.LastModifiedBy, tool)
+	}
+}
+
+func TestImportPortRange(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1"}}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}], "ports": [
+		{"ip_address": "10.0.0.1", "port": "1024"},
+		{"ip_address": "10.0.0.1", "port": "1025"},
+		{"ip_address": "10.0.0.1", "port": "8443"}
+	]}`)
+	r := runDrone(t, m, "-port-range", "1-1024,8443", input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	h := findHost(t, m.lastImport(), "10.0.0.1")
+	if len(h.Services) != 2 || h.Services[0].Port != 1024 || h.Services[1].Port != 8443 {
+		t.Errorf("services = %+v, want 1024 and 8443", h.Services)
+	}
+	if !strings.Contains(r.stderr, "Skipped 1 ports outside -port-range") {
+		t.Errorf("the skipped port was not counted:\n%s", r.stderr)
+	}
+	if r := runDrone(t, m, "-port-range", "1024-1", input); r.code == 0 {
+		t.Errorf("a malformed -port-range was accepted")
+	}
+}
+
+func TestImportMergeContacts(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", People: []lair.Person{
+		{ID: "p1", FirstName: "Alice", Emails: []string{"alice@example.com"}},
+	}})
+	input := writeInput(t, "recon.json", `{"contacts": [{"first_name": "Alice", "email": "alice@example.com", "title": "CTO"}]}`)
+	if r := runDrone(t, m, "-merge-contacts", input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	people := m.lastImport().People
+	if len(people) != 1 || people[0].ID != "p1" || people[0].Department != "CTO" {
+		t.Errorf("people = %+v, want p1 with the title CTO", people)
+	}
+}
+
+func TestImportThroughTransportClient(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.1"}}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "b.example.com", "ip_address": "10.0.0.1"}]}`)
+	if r := runDrone(t, m, "-stream-import", "-force-ports", input); r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	h := findHost(t, m.lastImport(), "10.0.0.1")
+	if strings.Join(h.Hostnames, ",") != "b.example.com" {
+		t.Errorf("hostnames = %v, want [b.example.com]", h.Hostnames)
+	}
+	if m.queries[0] != "force-ports=true" {
+		t.Errorf("import query = %q, want force-ports=true", m.queries[0])
+	}
+}
+
+func TestImportReconcileByMAC(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.5", MAC: "00:1a:2b:3c:4d:5e"}}})
+	input := writeInput(t, "recon.json", `{
+		"hosts": [{"host": "printer.example.com", "ip_address": "10.0.0.9", "mac": "00-1A-2B-3C-4D-5E"}],
+		"ports": [{"ip_address": "10.0.0.9", "port": "631"}]
+	}`)
+	r := runDrone(t, m, "-reconcile-by-mac", input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	imported := m.lastImport()
+	if len(imported.Hosts) != 1 {
+		t.Fatalf("imported %d hosts, want only the lair host", len(imported.Hosts))
+	}
+	h := findHost(t, imported, "10.0.0.5")
+	if strings.Join(h.Hostnames, ",") != "printer.example.com" || len(h.Services) != 1 || h.Services[0].Port != 631 {
+		t.Errorf("host = %+v, want the hostname and port of 10.0.0.9", h)
+	}
+	if r.stdout != "" {
+		t.Errorf("hosts not found in lair = %q, want none", r.stdout)
+	}
+
+	// Without the flag the new address is not found.
+	m = newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{{IPv4: "10.0.0.5", MAC: "00:1a:2b:3c:4d:5e"}}})
+	if r := runDrone(t, m, input); strings.TrimSpace(r.stdout) != "10.0.0.9" {
+		t.Errorf("hosts not found in lair without -reconcile-by-mac = %q, want 10.0.0.9", r.stdout)
+	}
+}
+
+// A host with a CIDR address is expanded before -exclude-hosts and
+// -ip-version are applied, so the lair hosts it expands to are filtered one by
+// one.
+func TestFiltersApplyToExpandedCIDR(t *testing.T) {
+	m := newMockLair(t, lair.Project{ID: "pid", Hosts: []lair.Host{
+		{IPv4: "10.0.0.1"},
+		{IPv4: "10.0.0.2"},
+	}})
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "net.example.com", "ip_address": "10.0.0.0/30"}]}`)
+	r := runDrone(t, m, "-expand-cidr", "-exclude-hosts", "10.0.0.2", "-ip-version", "4", input)
+	if r.code != 0 {
+		t.Fatalf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+	project := m.lastImport()
+	if got := findHost(t, project, "10.0.0.1").Hostnames; len(got) != 1 || got[0] != "net.example.com" {
+		t.Errorf("hostnames of 10.0.0.1 = %v, want net.example.com", got)
+	}
+	if got := findHost(t, project, "10.0.0.2").Hostnames; len(got) != 0 {
+		t.Errorf("hostnames of excluded 10.0.0.2 = %v, want none", got)
+	}
+}
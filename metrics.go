@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// runMetrics are the per-run statistics pushed to a Prometheus pushgateway.
+type runMetrics struct {
+	HostsImported   int
+	HostnamesAdded  int
+	Netblocks       int
+	People          int
+	NotFound        int
+	DurationSeconds float64
+}
+
+// pushMetrics pushes m to the pushgateway at url, grouped by project.
+func pushMetrics(url, project string, m runMetrics) error {
+	pusher := push.New(url, "drone_recon_ng").Grouping("project", project)
+	for _, g := range []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"hosts_imported", "Hosts sent to lair by the last run.", float64(m.HostsImported)},
+		{"hostnames_added", "Hostnames added to lair hosts by the last run.", float64(m.HostnamesAdded)},
+		{"netblocks", "Netblocks sent to lair by the last run.", float64(m.Netblocks)},
+		{"people", "People sent to lair by the last run.", float64(m.People)},
+		{"notfound_count", "Recon-ng hosts that did not exist in lair in the last run.", float64(m.NotFound)},
+		{"duration_seconds", "Duration of the last run.", m.DurationSeconds},
+	} {
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "drone_recon_ng",
+			Name:      g.name,
+			Help:      g.help,
+		})
+		gauge.Set(g.value)
+		pusher = pusher.Collector(gauge)
+	}
+	return pusher.Push()
+}
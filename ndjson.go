@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+
+	lair "github.com/lair-framework/go-lair"
+)
+
+// ndjsonRecord is a single line of -output-ndjson.
+type ndjsonRecord struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// writeNDJSON writes every host, netblock and person in project to filename,
+// one JSON object per line.
+func writeNDJSON(filename string, project *lair.Project) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	records := []ndjsonRecord{}
+	for _, h := range project.Hosts {
+		records = append(records, ndjsonRecord{Type: "host", Data: h})
+	}
+	for _, n := range project.Netblocks {
+		records = append(records, ndjsonRecord{Type: "netblock", Data: n})
+	}
+	for _, p := range project.People {
+		records = append(records, ndjsonRecord{Type: "person", Data: p})
+	}
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
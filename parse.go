@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+// inputError is returned by parseFiles when an input file cannot be read or
+// holds no data.
+type inputError struct {
+	msg string
+}
+
+func (e *inputError) Error() string {
+	return e.msg
+}
+
+// parseOptions control how recon-ng exports are parsed.
+type parseOptions struct {
+	// format is the format of every file, or empty to detect the format of
+	// each file from its extension.
+	format string
+	// fields overrides the keys used for recon-ng columns.
+	fields fieldMap
+	// rootKey is the key the tables are nested under, or empty to detect a
+	// single level of nesting.
+	rootKey string
+	// decodeHTML decodes HTML entities in scraped text columns.
+	decodeHTML bool
+	// includeDeleted keeps rows marked as deleted or hidden in recon-ng.
+	includeDeleted bool
+	// expectSchema is the recon-ng schema the files are expected to use, or
+	// empty to accept any.
+	expectSchema string
+	// minConfidence drops rows with a lower confidence column, and
+	// requireConfidence also drops rows without one.
+	minConfidence     float64
+	requireConfidence bool
+	// fileHashes are the expected SHA-256 hashes of the files, in order. When
+	// empty the hashes are logged instead.
+	fileHashes []string
+	// bestEffort imports the complete tables of truncated files instead of
+	// failing.
+	bestEffort bool
+}
+
+// parseFiles reads, parses and merges each recon-ng export in filenames.
+// Every file is merged before reconciliation so that the data for a single IP
+// can be split across any number of files.
+func parseFiles(filenames []string, opts parseOptions) (*reconng.Data, *extraData, error) {
+	recData := &reconng.Data{}
+	recExtra := &extraData{}
+	for i, filename := range filenames {
+		buf, err := readInput(filename)
+		if err != nil {
+			return nil, nil, &inputError{fmt.Sprintf("could not open file %s: %s", filename, err.Error())}
+		}
+		sum := sha256.Sum256(buf)
+		hash := hex.EncodeToString(sum[:])
+		if len(opts.fileHashes) == 0 {
+			log.Printf("Info: SHA-256 of %s is %s\n", filename, hash)
+		} else if i >= len(opts.fileHashes) || !strings.EqualFold(strings.TrimSpace(opts.fileHashes[i]), hash) {
+			return nil, nil, &inputError{fmt.Sprintf("SHA-256 of %s is %s, which does not match -verify-file-hash", filename, hash)}
+		}
+		// Exports written on Windows may start with a UTF-8 byte order mark,
+		// which encoding/json rejects.
+		buf = bytes.TrimPrefix(buf, []byte("\xef\xbb\xbf"))
+		if len(bytes.TrimSpace(buf)) == 0 {
+			return nil, nil, &inputError{fmt.Sprintf("input file %s is empty", filename)}
+		}
+		fileFormat := opts.format
+		if fileFormat == "" {
+			fileFormat = detectFormat(filename)
+		}
+		if fileFormat == "xml" {
+			buf, err = xmlToJSON(buf)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not parse recon-ng XML in %s: %s", filename, err.Error())
+			}
+		}
+		if fileFormat != "xml" && truncated(buf) {
+			if !opts.bestEffort {
+				return nil, nil, &inputError{fmt.Sprintf("input file %s appears truncated or incomplete, re-export it from recon-ng", filename)}
+			}
+			var tables []string
+			buf, tables = salvageTables(buf)
+			log.Printf("Warning: Input file %s appears truncated, importing only the complete tables: %s\n", filename, strings.Join(tables, ", "))
+		}
+		buf, err = unwrapRoot(buf, opts.rootKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse recon-ng data in %s: %s", filename, err.Error())
+		}
+		buf, err = opts.fields.apply(buf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not apply field map to %s: %s", filename, err.Error())
+		}
+		if err := checkSchema(buf, opts.expectSchema); err != nil {
+			return nil, nil, fmt.Errorf("could not parse recon-ng data in %s: %s", filename, err.Error())
+		}
+		if !opts.includeDeleted {
+			var dropped int
+			buf, dropped, err = dropDeleted(buf)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not parse recon-ng data in %s: %s", filename, err.Error())
+			}
+			if dropped > 0 {
+				log.Printf("Info: Skipped %d deleted or hidden rows in %s\n", dropped, filename)
+			}
+		}
+		if opts.minConfidence > 0 || opts.requireConfidence {
+			var dropped int
+			buf, dropped, err = dropUnconfident(buf, opts.minConfidence, opts.requireConfidence)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not parse recon-ng data in %s: %s", filename, err.Error())
+			}
+			if dropped > 0 {
+				log.Printf("Info: Skipped %d rows below the minimum confidence in %s\n", dropped, filename)
+			}
+		}
+		if opts.decodeHTML {
+			buf, err = decodeEntities(buf)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not parse recon-ng data in %s: %s", filename, err.Error())
+			}
+		}
+		data, err := reconng.Parse(buf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse recon-ng data in %s: %s", filename, err.Error())
+		}
+		mergeData(recData, data)
+		extra, err := parseExtra(buf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse recon-ng data in %s: %s", filename, err.Error())
+		}
+		mergeExtra(recExtra, extra)
+	}
+	return recData, recExtra, nil
+}
+
+// deletedColumns are the columns that mark a recon-ng row as soft deleted or
+// hidden from the workspace.
+var deletedColumns = []string{"deleted", "hidden", "trash"}
+
+// dropDeleted removes the rows of every table in buf that have a true value
+// in any of the deletedColumns. It returns the number of rows removed.
+func dropDeleted(buf []byte) ([]byte, int, error) {
+	return filterRows(buf, func(row map[string]json.RawMessage) bool {
+		return !isDeleted(row)
+	})
+}
+
+// filterRows removes the rows of every table in buf for which keep returns
+// false. It returns the number of rows removed.
+func filterRows(buf []byte, keep func(map[string]json.RawMessage) bool) ([]byte, int, error) {
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, 0, err
+	}
+	dropped := 0
+	for table, raw := range doc {
+		rows := []map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			// Not a table of rows, such as the workspace name.
+			continue
+		}
+		kept := rows[:0]
+		for _, row := range rows {
+			if !keep(row) {
+				dropped++
+				continue
+			}
+			kept = append(kept, row)
+		}
+		if len(kept) == len(rows) {
+			continue
+		}
+		b, err := json.Marshal(kept)
+		if err != nil {
+			return nil, 0, err
+		}
+		doc[table] = b
+	}
+	if dropped == 0 {
+		return buf, 0, nil
+	}
+	b, err := json.Marshal(doc)
+	return b, dropped, err
+}
+
+// dropUnconfident removes the rows of every table in buf with a confidence
+// column below min. Rows without a confidence are removed only when require
+// is set. It returns the number of rows removed.
+func dropUnconfident(buf []byte, min float64, require bool) ([]byte, int, error) {
+	return filterRows(buf, func(row map[string]json.RawMessage) bool {
+		raw, ok := row["confidence"]
+		if !ok {
+			return !require
+		}
+		var v flexString
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return !require
+		}
+		confidence, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+		if err != nil {
+			return !require
+		}
+		return confidence >= min
+	})
+}
+
+// isDeleted reports whether row has a true value in any of the
+// deletedColumns. Booleans, numbers and strings such as "yes" are accepted.
+func isDeleted(row map[string]json.RawMessage) bool {
+	for _, column := range deletedColumns {
+		raw, ok := row[column]
+		if !ok {
+			continue
+		}
+		var v flexString
+		if err := json.Unmarshal(raw, &v); err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(string(v))) {
+		case "1", "true", "yes", "y":
+			return true
+		}
+	}
+	return false
+}
+
+// truncated reports whether buf is JSON that ends before the document is
+// complete, as left behind by an interrupted export.
+func truncated(buf []byte) bool {
+	var v interface{}
+	err := json.Unmarshal(buf, &v)
+	if serr, ok := err.(*json.SyntaxError); ok {
+		return serr.Error() == "unexpected end of JSON input"
+	}
+	return false
+}
+
+// salvageTables returns a document holding the top level values of buf that
+// were complete before it was truncated, along with their keys.
+func salvageTables(buf []byte) ([]byte, []string) {
+	doc := map[string]json.RawMessage{}
+	keys := []string{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return []byte("{}"), keys
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			break
+		}
+		key, ok := t.(string)
+		if !ok {
+			break
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			break
+		}
+		doc[key] = raw
+		keys = append(keys, key)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return []byte("{}"), keys
+	}
+	return b, keys
+}
+
+// unwrapRoot returns the object nested under rootKey in buf. When rootKey is
+// empty and buf holds no recon-ng tables, but a single object, that object is
+// returned instead. Otherwise buf is returned unchanged.
+func unwrapRoot(buf []byte, rootKey string) ([]byte, error) {
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	if rootKey != "" {
+		raw, ok := doc[rootKey]
+		if !ok {
+			return nil, fmt.Errorf("root key %s not found", rootKey)
+		}
+		return raw, nil
+	}
+	objects := []string{}
+	for key, raw := range doc {
+		switch trimmed := bytes.TrimSpace(raw); {
+		case len(trimmed) > 0 && trimmed[0] == '[':
+			// The document already has tables at its root.
+			return buf, nil
+		case len(trimmed) > 0 && trimmed[0] == '{':
+			objects = append(objects, key)
+		}
+	}
+	switch len(objects) {
+	case 0:
+		return buf, nil
+	case 1:
+		return doc[objects[0]], nil
+	}
+	sort.Strings(objects)
+	return nil, fmt.Errorf("tables may be nested under any of %s, use -root-key to choose one", strings.Join(objects, ", "))
+}
+
+// limitData sorts the recon-ng hosts, netblocks and contacts and then keeps at
+// most limit of each, where the rows for a single IP count as one host. It
+// returns the number of hosts, netblocks and contacts that were dropped.
+func limitData(data *reconng.Data, limit int) (int, int, int) {
+	sort.SliceStable(data.Hosts, func(i, j int) bool {
+		if data.Hosts[i].IPAddress != data.Hosts[j].IPAddress {
+			return lessIP(data.Hosts[i].IPAddress, data.Hosts[j].IPAddress)
+		}
+		return strings.ToLower(data.Hosts[i].Name) < strings.ToLower(data.Hosts[j].Name)
+	})
+	hosts := []reconng.Host{}
+	ips := map[string]bool{}
+	for _, h := range data.Hosts {
+		if !ips[h.IPAddress] && len(ips) == limit {
+			continue
+		}
+		ips[h.IPAddress] = true
+		hosts = append(hosts, h)
+	}
+	droppedHosts := len(uniqueIPs(data.Hosts)) - len(ips)
+	data.Hosts = hosts
+
+	sort.SliceStable(data.NetBlocks, func(i, j int) bool {
+		return data.NetBlocks[i].Netblock < data.NetBlocks[j].Netblock
+	})
+	droppedNetblocks := 0
+	if len(data.NetBlocks) > limit {
+		droppedNetblocks = len(data.NetBlocks) - limit
+		data.NetBlocks = data.NetBlocks[:limit]
+	}
+
+	sort.SliceStable(data.Contacts, func(i, j int) bool {
+		return strings.ToLower(data.Contacts[i].Email) < strings.ToLower(data.Contacts[j].Email)
+	})
+	droppedContacts := 0
+	if len(data.Contacts) > limit {
+		droppedContacts = len(data.Contacts) - limit
+		data.Contacts = data.Contacts[:limit]
+	}
+	return droppedHosts, droppedNetblocks, droppedContacts
+}
+
+// uniqueIPs returns the distinct IPs in results.
+func uniqueIPs(results []reconng.Host) map[string]bool {
+	ips := map[string]bool{}
+	for _, r := range results {
+		ips[r.IPAddress] = true
+	}
+	return ips
+}
+
+// splitMultiIPHosts returns results with every host whose ip_address holds a
+// comma separated list of IPs replaced by one host per IP.
+func splitMultiIPHosts(results []reconng.Host) []reconng.Host {
+	split := []reconng.Host{}
+	for _, r := range results {
+		if !strings.Contains(r.IPAddress, ",") {
+			split = append(split, r)
+			continue
+		}
+		for _, ip := range strings.Split(r.IPAddress, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				single := r
+				single.IPAddress = ip
+				split = append(split, single)
+			}
+		}
+	}
+	return split
+}
+
+// entityTables are the recon-ng tables holding text scraped from web pages,
+// which may contain HTML entities.
+var entityTables = []string{"hosts", "contacts", "companies"}
+
+// decodeEntities decodes the HTML entities, such as &amp; and &#39;, in every
+// string column of the entityTables in buf.
+func decodeEntities(buf []byte) ([]byte, error) {
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	for _, table := range entityTables {
+		raw, ok := doc[table]
+		if !ok {
+			continue
+		}
+		rows := []map[string]interface{}{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&rows); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			for column, v := range row {
+				if s, ok := v.(string); ok {
+					row[column] = html.UnescapeString(s)
+				}
+			}
+		}
+		b, err := json.Marshal(rows)
+		if err != nil {
+			return nil, err
+		}
+		doc[table] = b
+	}
+	return json.Marshal(doc)
+}
+
+// readInput reads the contents of filename, or of stdin when filename is "-".
+func readInput(filename string) ([]byte, error) {
+	if filename == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// mergeData appends all of the recon-ng records in src to dst.
+func mergeData(dst, src *reconng.Data) {
+	dst.Hosts = append(dst.Hosts, src.Hosts...)
+	dst.NetBlocks = append(dst.NetBlocks, src.NetBlocks...)
+	dst.Contacts = append(dst.Contacts, src.Contacts...)
+	dst.Credentials = append(dst.Credentials, src.Credentials...)
+}
+
+// normalizeData cleans up the parsed recon-ng data before it is reconciled.
+// Hosts whose ip_address holds a comma separated list of IPs are split into
+// one host per IP, hostnames longer than maxHostnameLength are dropped with a
+// warning, unless maxHostnameLength is zero, as are hostnames with more than
+// maxSubdomainLabels labels, and duplicate host rows are removed. The extra
+// columns of a host whose hostname is dropped stay attached to it in extra.
+func normalizeData(data *reconng.Data, extra *extraData, maxHostnameLength, maxSubdomainLabels int) {
+	hosts := []reconng.Host{}
+	seen := map[string]bool{}
+	for _, result := range splitMultiIPHosts(data.Hosts) {
+		original := result
+		if maxHostnameLength > 0 && len(result.Name) > maxHostnameLength {
+			prefix := result.Name
+			if len(prefix) > 32 {
+				prefix = prefix[:32]
+			}
+			log.Printf("Warning: Skipping %d character hostname for %s beginning with %q\n", len(result.Name), result.IPAddress, prefix)
+			result.Name = ""
+		}
+		if labels := strings.Count(result.Name, ".") + 1; maxSubdomainLabels > 0 && result.Name != "" && labels > maxSubdomainLabels {
+			log.Printf("Warning: Skipping %d label hostname %s for %s\n", labels, result.Name, result.IPAddress)
+			result.Name = ""
+		}
+		extra.rekey(original, result)
+		key := result.IPAddress + " " + strings.ToLower(result.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		hosts = append(hosts, result)
+	}
+	data.Hosts = hosts
+}
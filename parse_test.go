@@ -0,0 +1,216 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+// writeInput writes content to a file named name in a temporary directory
+// and returns its path.
+func writeInput(t *testing.T, name, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestParseFilesEmpty(t *testing.T) {
+	for name, content := range map[string]string{"empty": "", "whitespace": "  \n\t\n  \n"} {
+		input := writeInput(t, "recon.json", content)
+		_, _, err := parseFiles([]string{input}, parseOptions{})
+		if _, ok := err.(*inputError); !ok || !strings.Contains(err.Error(), "is empty") {
+			t.Errorf("%s file: parseFiles = %v, want an empty input error", name, err)
+		}
+	}
+}
+
+func TestParseFilesStripsBOM(t *testing.T) {
+	bom := "\xef\xbb\xbf" + `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}]}`
+	input := writeInput(t, "recon.json", bom)
+	data, _, err := parseFiles([]string{input}, parseOptions{})
+	if err != nil {
+		t.Fatalf("parseFiles = %v", err)
+	}
+	if len(data.Hosts) != 1 || data.Hosts[0].Name != "a.example.com" {
+		t.Errorf("hosts = %v, want a.example.com", data.Hosts)
+	}
+
+	// Input read from stdin has the mark stripped as well.
+	f, err := os.Open(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+	data, _, err = parseFiles([]string{"-"}, parseOptions{})
+	if err != nil {
+		t.Fatalf("parseFiles from stdin = %v", err)
+	}
+	if len(data.Hosts) != 1 || data.Hosts[0].IPAddress != "10.0.0.1" {
+		t.Errorf("hosts from stdin = %v, want 10.0.0.1", data.Hosts)
+	}
+}
+
+func TestUnwrapRoot(t *testing.T) {
+	flat := `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}]}`
+	wrapped := `{"data": ` + flat + `, "version": "5"}`
+	tests := []struct {
+		name, buf, rootKey string
+		wantErr            bool
+	}{
+		{"flat", flat, "", false},
+		{"wrapped", wrapped, "", false},
+		{"root key", `{"meta": {"source": "x"}, "data": ` + flat + `}`, "data", false},
+		{"ambiguous", `{"meta": {"source": "x"}, "data": ` + flat + `}`, "", true},
+		{"missing root key", flat, "data", true},
+	}
+	for _, tt := range tests {
+		buf, err := unwrapRoot([]byte(tt.buf), tt.rootKey)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: unwrapRoot returned no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unwrapRoot = %v", tt.name, err)
+			continue
+		}
+		data, err := reconng.Parse(buf)
+		if err != nil || len(data.Hosts) != 1 || data.Hosts[0].Name != "a.example.com" {
+			t.Errorf("%s: parsed %v, %v, want a.example.com", tt.name, data, err)
+		}
+	}
+}
+
+func TestSplitMultiIPHosts(t *testing.T) {
+	results := []reconng.Host{
+		{Name: "a.example.com", IPAddress: "10.0.0.1"},
+		{Name: "b.example.com", IPAddress: "10.0.0.2, 10.0.0.3,,"},
+	}
+	split := splitMultiIPHosts(results)
+	var got []string
+	for _, r := range split {
+		got = append(got, r.Name+" "+r.IPAddress)
+	}
+	want := "a.example.com 10.0.0.1,b.example.com 10.0.0.2,b.example.com 10.0.0.3"
+	if strings.Join(got, ",") != want {
+		t.Errorf("splitMultiIPHosts = %v, want %s", got, want)
+	}
+}
+
+func TestParseFilesDecodesEntities(t *testing.T) {
+	input := writeInput(t, "recon.json", `{"contacts": [{"first_name": "Pat", "last_name": "O&#39;Brien", "title": "R&amp;D"}]}`)
+	data, _, err := parseFiles([]string{input}, parseOptions{decodeHTML: true})
+	if err != nil {
+		t.Fatalf("parseFiles = %v", err)
+	}
+	if c := data.Contacts[0]; c.LastName != "O'Brien" || c.Title != "R&D" {
+		t.Errorf("contact = %+v, want O'Brien in R&D", c)
+	}
+
+	data, _, err = parseFiles([]string{input}, parseOptions{})
+	if err != nil {
+		t.Fatalf("parseFiles = %v", err)
+	}
+	if c := data.Contacts[0]; c.LastName != "O&#39;Brien" {
+		t.Errorf("last name without decoding = %q, want the raw value", c.LastName)
+	}
+}
+
+func TestNormalizeDataSkipsLongHostnames(t *testing.T) {
+	long := strings.Repeat("a", 496) + ".com"
+	data := &reconng.Data{Hosts: []reconng.Host{
+		{IPAddress: "10.0.0.1", Name: long},
+		{IPAddress: "10.0.0.2", Name: "ok.example.com"},
+	}}
+	normalizeData(data, &extraData{}, 253, 0)
+	if len(data.Hosts) != 2 {
+		t.Fatalf("hosts = %v, want both kept", data.Hosts)
+	}
+	if data.Hosts[0].Name != "" || data.Hosts[0].IPAddress != "10.0.0.1" {
+		t.Errorf("host = %+v, want 10.0.0.1 without its 500 character hostname", data.Hosts[0])
+	}
+	if data.Hosts[1].Name != "ok.example.com" {
+		t.Errorf("hostname = %q, want ok.example.com", data.Hosts[1].Name)
+	}
+
+	// A limit of zero disables the guard.
+	data = &reconng.Data{Hosts: []reconng.Host{{IPAddress: "10.0.0.1", Name: long}}}
+	normalizeData(data, &extraData{}, 0, 0)
+	if data.Hosts[0].Name != long {
+		t.Errorf("hostname was dropped with the guard disabled")
+	}
+}
+
+func TestNormalizeDataSkipsDeepHostnames(t *testing.T) {
+	data := &reconng.Data{Hosts: []reconng.Host{
+		{IPAddress: "10.0.0.1", Name: "a.b.c.d.e.f.g.h.example.com"},
+		{IPAddress: "10.0.0.2", Name: "d.c.b.example.com"},
+	}}
+	normalizeData(data, &extraData{}, 0, 5)
+	if data.Hosts[0].Name != "" {
+		t.Errorf("10 label hostname = %q, want it skipped at a limit of 5", data.Hosts[0].Name)
+	}
+	if data.Hosts[1].Name != "d.c.b.example.com" {
+		t.Errorf("5 label hostname = %q, want it kept", data.Hosts[1].Name)
+	}
+}
+
+func TestParseFilesTruncated(t *testing.T) {
+	input := writeInput(t, "recon.json", `{"hosts": [{"host": "a.example.com", "ip_address": "10.0.0.1"}], "contacts": [{"first_name": "Al`)
+	_, _, err := parseFiles([]string{input}, parseOptions{})
+	if _, ok := err.(*inputError); !ok || !strings.Contains(err.Error(), "appears truncated") {
+		t.Fatalf("parseFiles = %v, want a truncated input error", err)
+	}
+
+	data, _, err := parseFiles([]string{input}, parseOptions{bestEffort: true})
+	if err != nil {
+		t.Fatalf("parseFiles with best effort = %v", err)
+	}
+	if len(data.Hosts) != 1 || data.Hosts[0].Name != "a.example.com" || len(data.Contacts) != 0 {
+		t.Errorf("best effort data = %+v, want only the complete hosts table", data)
+	}
+}
+
+func TestDropUnconfident(t *testing.T) {
+	buf := []byte(`{"hosts": [
+		{"host": "below", "confidence": 0.49},
+		{"host": "at", "confidence": "0.5"},
+		{"host": "above", "confidence": 0.51},
+		{"host": "unscored"}
+	]}`)
+	tests := []struct {
+		require bool
+		want    []string
+	}{
+		{false, []string{"at", "above", "unscored"}},
+		{true, []string{"at", "above"}},
+	}
+	for _, tt := range tests {
+		out, dropped, err := dropUnconfident(buf, 0.5, tt.require)
+		if err != nil {
+			t.Fatalf("dropUnconfident = %v", err)
+		}
+		data, err := reconng.Parse(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, h := range data.Hosts {
+			got = append(got, h.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") || dropped != 4-len(tt.want) {
+			t.Errorf("require %v: kept %v, dropped %d, want %v", tt.require, got, dropped, tt.want)
+		}
+	}
+}
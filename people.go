@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+
+	lair "github.com/lair-framework/go-lair"
+)
+
+// mergeContacts replaces each person in people that shares an email with a
+// person in existing by that existing person, enriched with the new person's
+// details. People that match the same existing person are merged into one.
+// It returns the people to send and the number that were merged.
+func mergeContacts(existing []lair.Person, people []lair.Person) ([]lair.Person, int) {
+	byEmail := map[string]int{}
+	for i, p := range existing {
+		for _, e := range p.Emails {
+			byEmail[strings.ToLower(strings.TrimSpace(e))] = i
+		}
+	}
+	merged := []lair.Person{}
+	sent := map[int]int{}
+	count := 0
+	for _, per := range people {
+		i, ok := -1, false
+		for _, e := range per.Emails {
+			if i, ok = byEmail[strings.ToLower(strings.TrimSpace(e))]; ok {
+				break
+			}
+		}
+		if !ok {
+			merged = append(merged, per)
+			continue
+		}
+		count++
+		if j, ok := sent[i]; ok {
+			merged[j] = mergePerson(merged[j], per)
+			continue
+		}
+		sent[i] = len(merged)
+		merged = append(merged, mergePerson(existing[i], per))
+	}
+	return merged, count
+}
+
+// mergePerson returns existing with the fields it lacks filled in from per
+// and the emails, phones and groups of per added. Fields that are already
+// set are left as they are.
+func mergePerson(existing, per lair.Person) lair.Person {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&existing.PrincipalName, per.PrincipalName)
+	fill(&existing.FirstName, per.FirstName)
+	fill(&existing.MiddleName, per.MiddleName)
+	fill(&existing.LastName, per.LastName)
+	fill(&existing.DisplayName, per.DisplayName)
+	fill(&existing.Department, per.Department)
+	fill(&existing.Description, per.Description)
+	fill(&existing.Address, per.Address)
+	existing.Emails = appendHostnames(append([]string{}, existing.Emails...), per.Emails...)
+	existing.Phones = append([]string{}, existing.Phones...)
+	for _, phone := range per.Phones {
+		existing.Phones = appendTag(existing.Phones, phone)
+	}
+	existing.Groups = append([]string{}, existing.Groups...)
+	for _, group := range per.Groups {
+		existing.Groups = appendTag(existing.Groups, group)
+	}
+	return existing
+}
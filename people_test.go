@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	lair "github.com/lair-framework/go-lair"
+)
+
+func TestMergeContacts(t *testing.T) {
+	existing := []lair.Person{{
+		ID:        "p1",
+		FirstName: "Alice",
+		Emails:    []string{"alice@example.com"},
+		Groups:    []string{"role:employee"},
+	}}
+	people := []lair.Person{
+		{FirstName: "Al", LastName: "Smith", Department: "CTO", Emails: []string{"Alice@Example.com"}, Groups: []string{"role:employee", "recon"}},
+		{FirstName: "Bob", Emails: []string{"bob@example.com"}},
+	}
+	merged, count := mergeContacts(existing, people)
+	if count != 1 || len(merged) != 2 {
+		t.Fatalf("merged %d into %d people, want 1 into 2", count, len(merged))
+	}
+	p := merged[0]
+	if p.ID != "p1" || p.FirstName != "Alice" || p.LastName != "Smith" || p.Department != "CTO" {
+		t.Errorf("merged person = %+v, want p1 Alice Smith with the harvested title", p)
+	}
+	if strings.Join(p.Emails, ",") != "alice@example.com" || strings.Join(p.Groups, ",") != "role:employee,recon" {
+		t.Errorf("emails = %v, groups = %v, want one email and both groups", p.Emails, p.Groups)
+	}
+	if merged[1].FirstName != "Bob" || merged[1].ID != "" {
+		t.Errorf("new person = %+v, want Bob created", merged[1])
+	}
+	if len(existing[0].Groups) != 1 {
+		t.Errorf("existing person was modified: %+v", existing[0])
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// portRange is an inclusive range of ports.
+type portRange struct {
+	low, high int
+}
+
+// portRanges is a set of port ranges parsed from a spec such as
+// "1-1024,8080,8443".
+type portRanges []portRange
+
+// parsePortRanges parses spec, a comma separated list of ports and inclusive
+// ranges of ports.
+func parsePortRanges(spec string) (portRanges, error) {
+	ranges := portRanges{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		low, err := parsePort(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		high := low
+		if len(bounds) == 2 {
+			if high, err = parsePort(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		if low > high {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, portRange{low, high})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no ports in %q", spec)
+	}
+	return ranges, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+// contains reports whether port is in any of the ranges.
+func (r portRanges) contains(port int) bool {
+	for _, pr := range r {
+		if port >= pr.low && port <= pr.high {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestParsePortRanges(t *testing.T) {
+	ranges, err := parsePortRanges(" 1-1024, 8080,8443 ")
+	if err != nil {
+		t.Fatalf("parsePortRanges = %v", err)
+	}
+	for port, want := range map[int]bool{1: true, 1024: true, 1025: false, 8079: false, 8080: true, 8443: true, 8444: false} {
+		if got := ranges.contains(port); got != want {
+			t.Errorf("contains(%d) = %v, want %v", port, got, want)
+		}
+	}
+
+	for _, spec := range []string{"", ",", "http", "0", "65536", "1024-1", "1-", "-80", "1-2-3", "80,x"} {
+		if _, err := parsePortRanges(spec); err == nil {
+			t.Errorf("parsePortRanges(%q) returned no error", spec)
+		}
+	}
+}
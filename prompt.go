@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// prompter asks the operator to confirm individual changes during an
+// -interactive import. A nil prompter confirms everything.
+type prompter struct {
+	ctx   context.Context
+	lines chan promptLine
+	out   io.Writer
+	// eof is set once the input has ended, after which every question is
+	// answered no.
+	eof bool
+}
+
+// promptLine is a line read from the prompter's input.
+type promptLine struct {
+	text string
+	err  error
+}
+
+// newPrompter returns a prompter reading from stdin, or nil when stdin is not
+// a terminal so that automated runs never block waiting for an answer.
+func newPrompter(ctx context.Context) *prompter {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return newReaderPrompter(ctx, os.Stdin, os.Stderr)
+}
+
+// newReaderPrompter returns a prompter reading answers from in and writing
+// questions to out. Lines are read in the background so that a question can
+// be abandoned when ctx is canceled.
+func newReaderPrompter(ctx context.Context, in io.Reader, out io.Writer) *prompter {
+	p := &prompter{ctx: ctx, lines: make(chan promptLine), out: out}
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			text, err := r.ReadString('\n')
+			p.lines <- promptLine{text, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// confirm prints the formatted question and returns true if the operator
+// answers yes. End of input is treated as no. The process exits if it is
+// interrupted while waiting for an answer.
+func (p *prompter) confirm(format string, a ...interface{}) bool {
+	ok, err := p.ask(format, a...)
+	if err != nil {
+		fmt.Fprintln(p.out)
+		fatalf(exitInterrupted, "Fatal: Interrupted\n")
+	}
+	return ok
+}
+
+// ask is confirm, returning the context's error if it is canceled before the
+// operator answers.
+func (p *prompter) ask(format string, a ...interface{}) (bool, error) {
+	if p == nil {
+		return true, nil
+	}
+	for !p.eof {
+		fmt.Fprintf(p.out, format+" [y/n] ", a...)
+		var line promptLine
+		select {
+		case line = <-p.lines:
+		case <-p.ctx.Done():
+			return false, p.ctx.Err()
+		}
+		switch strings.ToLower(strings.TrimSpace(line.text)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		if line.err != nil {
+			fmt.Fprintln(p.out)
+			p.eof = true
+		}
+	}
+	return false, nil
+}
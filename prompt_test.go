@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrompterAnswers(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newReaderPrompter(context.Background(), strings.NewReader("maybe\ny\nNO\n"), out)
+	if ok, err := p.ask("Change %s?", "a"); !ok || err != nil {
+		t.Errorf("first ask = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := p.ask("Change %s?", "b"); ok || err != nil {
+		t.Errorf("second ask = %v, %v, want false, nil", ok, err)
+	}
+	// End of input answers no without blocking.
+	if ok, err := p.ask("Change %s?", "c"); ok || err != nil {
+		t.Errorf("ask at end of input = %v, %v, want false, nil", ok, err)
+	}
+	if ok, err := p.ask("Change %s?", "d"); ok || err != nil {
+		t.Errorf("ask after end of input = %v, %v, want false, nil", ok, err)
+	}
+	if got := strings.Count(out.String(), "Change a? [y/n] "); got != 2 {
+		t.Errorf("asked about a %d times, want 2 after an invalid answer", got)
+	}
+}
+
+func TestPrompterCanceled(t *testing.T) {
+	in, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newReaderPrompter(ctx, in, &bytes.Buffer{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.ask("Create host?")
+		done <- err
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("ask = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ask did not return after the context was canceled")
+	}
+}
+
+func TestNilPrompterConfirms(t *testing.T) {
+	var p *prompter
+	if !p.confirm("Change?") {
+		t.Error("nil prompter did not confirm")
+	}
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	lair "github.com/lair-framework/go-lair"
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+// importedColumns are the recon-ng columns that the reconciler copies into
+// lair, or uses to place the rows it imports, for each table.
+// -print-field-coverage reports every other column as ignored, so this must
+// be kept in step with the conversions below.
+var importedColumns = map[string][]string{
+	"hosts":       {"host", "ip_address", "status", "os", "os_confidence", "module", "mac", "timestamp"},
+	"contacts":    {"first_name", "middle_name", "last_name", "email", "title", "region", "phone"},
+	"credentials": {"username", "password", "hash", "leak"},
+	"netblocks":   {"netblock", "org_handle", "email"},
+	"ports":       {"ip_address", "port", "protocol", "timestamp"},
+}
+
+// reconciler merges parsed recon-ng data into an exported lair project and
+// builds the project to import.
+type reconciler struct {
+	opts        *options
+	data        *reconng.Data
+	extra       *extraData
+	prompt      *prompter
+	collectedAt string
+
+	hostTags     []string
+	netblockTags []string
+	peopleTags   []string
+
+	// exproject is reconciled in place, while exported keeps the hosts as
+	// they were exported.
+	exproject lair.Project
+	exported  lair.Project
+	project   *lair.Project
+	hosts     *hostIndex
+
+	// The tags of existing hosts are merged by the API server, so only the
+	// tags added by this import are sent.
+	addedTags map[string][]string
+	tagSet    map[string]bool
+
+	updated      map[string]bool
+	notFound     map[string][]reconng.Host
+	servicesByIP map[string][]lair.Service
+	macAliases   map[string]string
+	divergences  []string
+}
+
+// newReconciler returns a reconciler for importing data into exproject, the
+// project as exported from lair.
+func newReconciler(o *options, exproject lair.Project, data *reconng.Data, extra *extraData, prompt *prompter, collectedAt string) *reconciler {
+	r := &reconciler{
+		opts:        o,
+		data:        data,
+		extra:       extra,
+		prompt:      prompt,
+		collectedAt: collectedAt,
+		exproject:   exproject,
+		addedTags:   map[string][]string{},
+		tagSet:      map[string]bool{},
+		updated:     map[string]bool{},
+		notFound:    map[string][]reconng.Host{},
+		macAliases:  map[string]string{},
+	}
+	r.hostTags, r.netblockTags, r.peopleTags = o.importTags()
+	if r.opts.dedupeExport {
+		var dups map[string]int
+		r.exproject.Hosts, dups = mergeDuplicateHosts(r.exproject.Hosts)
+		for _, ip := range sortedKeys(dups) {
+			log.Printf("Warning: Merging %d duplicate lair hosts for %s into one host\n", dups[ip], ip)
+		}
+		for _, h := range r.exproject.Hosts {
+			if dups[h.IPv4] > 0 {
+				r.addedTags[h.IPv4] = append([]string{}, h.Tags...)
+			}
+		}
+	}
+	// Reconciliation modifies exproject, so keep a copy of the hosts as they
+	// were exported.
+	r.exported = r.exproject
+	r.exported.Hosts = make([]lair.Host, len(r.exproject.Hosts))
+	for i, h := range r.exproject.Hosts {
+		r.exported.Hosts[i] = copyHost(h)
+	}
+
+	r.project = &lair.Project{
+		ID:   r.exproject.ID,
+		Tool: tool,
+	}
+	// The same command is only recorded once so that repeated runs do not
+	// fill the project's command history with identical entries.
+	command := lair.Command{Tool: tool}
+	if !hasCommand(r.exproject.Commands, command) {
+		r.project.Commands = append(r.project.Commands, command)
+	}
+	// Lair commands have no run time, so the collection time is recorded in a
+	// project note, once for each distinct time.
+	collectedNote := lair.Note{Title: "recon-ng collected", Content: r.collectedAt, LastModifiedBy: tool}
+	if !hasNoteValue(r.exproject.Notes, collectedNote.Title, collectedNote.Content) {
+		r.project.Notes = append(r.project.Notes, collectedNote)
+	}
+	return r
+}
+
+// reconcileHosts merges the recon-ng hosts and ports into the exported hosts
+// and collects the hosts that are not in lair.
+func (r *reconciler) reconcileHosts() {
+	r.splitCIDRs()
+	r.filterHosts()
+	r.matchHosts()
+	r.mergeNotFound()
+	r.mergePorts()
+}
+
+// splitCIDRs imports the hosts with a CIDR address as netblocks, or expands
+// them to the lair hosts they contain with -expand-cidr.
+func (r *reconciler) splitCIDRs() {
+	var cidrNetblocks []reconng.NetBlock
+	r.data.Hosts, cidrNetblocks = splitCIDRHosts(r.data.Hosts, r.exproject.Hosts, r.opts.expandCIDR, r.extra)
+	if len(cidrNetblocks) > 0 {
+		log.Printf("Info: Importing %d hosts with a CIDR address as netblocks\n", len(cidrNetblocks))
+		r.data.NetBlocks = append(r.data.NetBlocks, cidrNetblocks...)
+	}
+}
+
+// filterHosts drops the recon-ng hosts and ports excluded by -exclude-hosts,
+// -scope-domains, -ip-version and -hosts-only-from-netblocks, and applies
+// -limit. It runs after splitCIDRs so that a host with a CIDR address is
+// filtered by each address it expands to rather than by the CIDR.
+func (r *reconciler) filterHosts() {
+	if r.opts.excluded != nil {
+		if dropped := excludeHosts(r.data, r.extra, r.opts.excluded); dropped > 0 {
+			log.Printf("Info: Excluded %d recon-ng hosts matched by -exclude-hosts\n", dropped)
+		}
+	}
+
+	if r.opts.scopeDomains != nil {
+		if dropped := scopeHosts(r.data, r.extra, r.opts.scopeDomains); dropped > 0 {
+			log.Printf("Info: Skipped %d recon-ng hosts outside -scope-domains\n", dropped)
+		}
+	}
+
+	if r.opts.ipVersion != "both" {
+		if dropped := filterIPVersion(r.data, r.extra, r.opts.ipVersion); dropped > 0 {
+			log.Printf("Info: Dropped %d recon-ng hosts that are not IPv%s\n", dropped, r.opts.ipVersion)
+		}
+	}
+
+	if r.opts.limit > 0 {
+		hosts, netblocks, contacts := limitData(r.data, r.opts.limit)
+		if hosts+netblocks+contacts > 0 {
+			log.Printf("Info: Limited the import to %d records per class, dropping %d hosts, %d netblocks and %d contacts\n",
+				r.opts.limit, hosts, netblocks, contacts)
+		}
+	}
+
+	if r.opts.hostsFromNetblocks {
+		// The netblocks are the project's implicit host scope. This runs
+		// after hosts with a CIDR address have been split off, since those
+		// are netblocks themselves.
+		cidrs := []string{}
+		for _, n := range r.exproject.Netblocks {
+			cidrs = append(cidrs, n.CIDR)
+		}
+		for _, n := range r.data.NetBlocks {
+			cidrs = append(cidrs, n.Netblock)
+		}
+		if len(cidrs) == 0 {
+			log.Println("Warning: -hosts-only-from-netblocks is set but there are no netblocks, no hosts will be imported")
+		}
+		dropped := netblockHosts(r.data, r.extra, cidrs)
+		for _, ip := range dropped {
+			log.Printf("Info: Skipping host %s, it is outside all netblocks\n", ip)
+		}
+		if len(dropped) > 0 {
+			log.Printf("Info: Skipped %d recon-ng hosts outside -hosts-only-from-netblocks\n", len(dropped))
+		}
+	}
+}
+
+// matchHosts merges every recon-ng host into the lair hosts it matches.
+func (r *reconciler) matchHosts() {
+	r.divergences = hostnameDivergences(r.exproject.Hosts, r.data.Hosts)
+	for _, msg := range r.divergences {
+		log.Printf("Warning: %s\n", msg)
+	}
+
+	// The project may hold a large number of hosts, so they are indexed by IP
+	// once rather than scanned for every recon-ng result.
+	// With -merge-by-long-ip IPv4 addresses are compared as integers, so
+	// differently formatted addresses still match.
+	r.hosts = newHostIndex(r.exproject.Hosts, r.opts.mergeByLongIP)
+	// With -reconcile-by-mac hosts are matched by MAC before IP, which keeps
+	// a host with a changed address on its lair record. macAliases maps the
+	// new address to the lair host's address so its ports follow it.
+	hostsByMAC := map[string][]int{}
+	if r.opts.reconcileByMAC {
+		for i, h := range r.exproject.Hosts {
+			if mac, ok := normalizeMAC(h.MAC); ok {
+				hostsByMAC[mac] = append(hostsByMAC[mac], i)
+			}
+		}
+	}
+	matchIndexes := func(result reconng.Host) ([]int, bool) {
+		if mac, ok := r.extra.hostMAC(result); ok && r.opts.reconcileByMAC && len(hostsByMAC[mac]) > 0 {
+			return hostsByMAC[mac], true
+		}
+		return r.hosts.lookup(result.IPAddress), false
+	}
+
+	for _, result := range r.data.Hosts {
+		found := false
+		indexes, byMAC := matchIndexes(result)
+		for _, i := range indexes {
+			h := r.exproject.Hosts[i]
+			found = true
+			if r.opts.newOnly {
+				continue
+			}
+			before := copyHost(h)
+			if byMAC && h.IPv4 != result.IPAddress {
+				log.Printf("Info: Matching %s onto lair host %s by MAC %s\n", result.IPAddress, h.IPv4, h.MAC)
+				r.exproject.Hosts[i].Notes = addNoteValue(r.exproject.Hosts[i].Notes, "recon-ng additional addresses", result.IPAddress)
+				r.macAliases[result.IPAddress] = h.IPv4
+			}
+			if mac, ok := r.extra.hostMAC(result); ok && r.opts.reconcileByMAC && h.MAC == "" {
+				r.exproject.Hosts[i].MAC = mac
+			}
+			r.exproject.Hosts[i].Hostnames = appendHostnames(r.exproject.Hosts[i].Hostnames, result.Name)
+			if st, ok := r.opts.statuses.lookup(r.extra.hostStatus(result)); ok {
+				current := r.exproject.Hosts[i].Status
+				if current == "" || current == st.Status || r.prompt.confirm("Change status of %s from %s to %s?", h.IPv4, current, st.Status) {
+					r.exproject.Hosts[i].Status = st.Status
+					r.exproject.Hosts[i].StatusMessage = st.Message
+				}
+			}
+			if guess, module, ok := r.extra.hostOS(result); ok {
+				// An existing OS is kept unless the operator chooses otherwise,
+				// the recon-ng guess is then recorded as a note so the
+				// alternative is not lost.
+				current := r.exproject.Hosts[i].OS
+				switch {
+				case current.Fingerprint == "":
+					r.exproject.Hosts[i].OS = guess
+				case current.Fingerprint == guess.Fingerprint:
+				case r.prompt != nil && r.prompt.confirm("Change OS of %s from %s to %s?", h.IPv4, current.Fingerprint, guess.Fingerprint):
+					r.exproject.Hosts[i].OS = guess
+				default:
+					r.exproject.Hosts[i].Notes = addNoteValue(r.exproject.Hosts[i].Notes, "recon-ng OS guess", osGuess(guess, module))
+				}
+			}
+			if t, ok := r.extra.hostTimestamp(result); ok {
+				r.exproject.Hosts[i].Notes = setLastSeen(r.exproject.Hosts[i].Notes, t)
+			}
+			for _, column := range r.opts.noteFieldFlags {
+				for _, v := range r.extra.hostColumn(result, column) {
+					r.exproject.Hosts[i].Notes = addNoteValue(r.exproject.Hosts[i].Notes, "recon-ng "+column, v)
+				}
+			}
+			if r.opts.groupTag {
+				for _, w := range r.extra.hostWorkspaces(result) {
+					r.addedTags[h.IPv4] = appendTag(r.addedTags[h.IPv4], "workspace:"+w)
+				}
+			}
+			if _, ok := r.tagSet[h.IPv4]; !ok {
+				r.tagSet[h.IPv4] = true
+				r.exproject.Hosts[i].Tags = append(r.exproject.Hosts[i].Tags, r.hostTags...)
+			}
+			// A result that adds nothing leaves the host untouched, so lair
+			// does not record a modification by the drone.
+			if hostChanged(before, r.exproject.Hosts[i], r.addedTags[h.IPv4]) {
+				r.exproject.Hosts[i].LastModifiedBy = tool
+				r.updated[h.IPv4] = true
+			}
+		}
+		if !found && result.IPAddress != "" {
+			r.notFound[result.IPAddress] = append(r.notFound[result.IPAddress], result)
+		}
+	}
+}
+
+// mergeNotFound merges the recon-ng hosts that are not in lair onto lair
+// hosts with a matching hostname, with -match-by-hostname and
+// -fuzzy-hostname-match.
+func (r *reconciler) mergeNotFound() {
+	// mergeOnto merges the recon-ng host ip, which is not in lair, onto the
+	// lair host at index i.
+	mergeOnto := func(i int, ip string) {
+		h := r.exproject.Hosts[i]
+		r.exproject.Hosts[i].Hostnames = appendHostnames(h.Hostnames, resultHostnames(r.notFound[ip])...)
+		r.exproject.Hosts[i].Notes = addNoteValue(h.Notes, "recon-ng additional addresses", ip)
+		r.exproject.Hosts[i].LastModifiedBy = tool
+		r.updated[h.IPv4] = true
+		delete(r.notFound, ip)
+	}
+
+	if r.opts.matchByHostname && !r.opts.newOnly {
+		for _, ip := range sortedIPs(r.notFound) {
+			for _, name := range resultHostnames(r.notFound[ip]) {
+				i, ok := hostByHostname(r.exproject.Hosts, name)
+				if !ok {
+					continue
+				}
+				log.Printf("Info: Merging %s (%s) onto lair host %s by hostname\n", ip, name, r.exproject.Hosts[i].IPv4)
+				mergeOnto(i, ip)
+				break
+			}
+		}
+	}
+
+	if r.opts.fuzzyHostnameMatch > 0 && !r.opts.newOnly {
+		for _, ip := range sortedIPs(r.notFound) {
+			for _, name := range resultHostnames(r.notFound[ip]) {
+				i, stored, distance, ok := fuzzyHostByHostname(r.exproject.Hosts, name, r.opts.fuzzyHostnameMatch)
+				if !ok {
+					continue
+				}
+				log.Printf("Warning: Fuzzy merging %s (%s) onto lair host %s (%s) at distance %d\n", ip, name, r.exproject.Hosts[i].IPv4, stored, distance)
+				mergeOnto(i, ip)
+				break
+			}
+		}
+	}
+}
+
+// mergePorts merges the recon-ng ports into the services of the lair hosts.
+func (r *reconciler) mergePorts() {
+	r.servicesByIP = map[string][]lair.Service{}
+	outOfRange := 0
+	for _, p := range r.extra.Ports {
+		svc, err := p.service()
+		if err != nil {
+			log.Printf("Warning: Skipping port for %s. Error %s\n", p.IPAddress, err.Error())
+			continue
+		}
+		if r.opts.ports != nil && !r.opts.ports.contains(svc.Port) {
+			outOfRange++
+			continue
+		}
+		ip := string(p.IPAddress)
+		// Ports follow the lair host their address was matched to, which
+		// may be spelled differently with -merge-by-long-ip.
+		if alias, ok := r.macAliases[ip]; ok {
+			ip = alias
+		} else if i := r.hosts.lookup(ip); len(i) > 0 {
+			ip = r.exproject.Hosts[i[0]].IPv4
+		}
+		r.servicesByIP[ip] = appendServices(r.servicesByIP[ip], svc)
+	}
+	if outOfRange > 0 {
+		log.Printf("Info: Skipped %d ports outside -port-range\n", outOfRange)
+	}
+	if !r.opts.newOnly {
+		for i, h := range r.exproject.Hosts {
+			services, ok := r.servicesByIP[h.IPv4]
+			if !ok {
+				continue
+			}
+			merged := appendServices(h.Services, services...)
+			if len(merged) > len(h.Services) {
+				r.exproject.Hosts[i].Services = merged
+				r.exproject.Hosts[i].LastModifiedBy = tool
+				r.updated[h.IPv4] = true
+			}
+		}
+	}
+}
+
+// buildProject returns the project to import, holding the reconciled hosts
+// and the recon-ng netblocks, contacts and credentials.
+func (r *reconciler) buildProject() *lair.Project {
+	r.addHosts()
+	r.addNetblocks()
+	r.addPeople()
+	r.addCredentials()
+	return r.project
+}
+
+// addHosts adds the reconciled lair hosts and, with -force-hosts, the hosts
+// that are not in lair to the project.
+func (r *reconciler) addHosts() {
+	exportedTags := map[string][]string{}
+	for _, h := range r.exported.Hosts {
+		exportedTags[h.IPv4] = append(exportedTags[h.IPv4], h.Tags...)
+	}
+
+	if !r.opts.newOnly {
+		for _, h := range r.exproject.Hosts {
+			hTags := append(append([]string{}, r.hostTags...), r.addedTags[h.IPv4]...)
+			if r.opts.ignoreCaseTags {
+				hTags = foldTags(hTags, exportedTags[h.IPv4])
+			}
+			r.project.Hosts = append(r.project.Hosts, lair.Host{
+				IPv4:           h.IPv4,
+				LongIPv4Addr:   h.LongIPv4Addr,
+				IsFlagged:      h.IsFlagged,
+				LastModifiedBy: h.LastModifiedBy,
+				MAC:            h.MAC,
+				OS:             h.OS,
+				Status:         h.Status,
+				StatusMessage:  h.StatusMessage,
+				Tags:           hTags,
+				Hostnames:      h.Hostnames,
+				Notes:          h.Notes,
+				Services:       h.Services,
+			})
+		}
+	}
+
+	if r.opts.forceHosts {
+		for _, ip := range sortedIPs(r.notFound) {
+			results := r.notFound[ip]
+			if !r.prompt.confirm("Create new host %s (%s)?", ip, strings.Join(resultHostnames(results), ",")) {
+				log.Printf("Info: Skipping new host %s\n", ip)
+				delete(r.notFound, ip)
+				continue
+			}
+			st := hostStatus{Status: statusGrey}
+			hostOS := lair.OS{}
+			mac := ""
+			notes := []lair.Note{}
+			tags := append([]string{}, r.hostTags...)
+			for _, res := range results {
+				if mapped, ok := r.opts.statuses.lookup(r.extra.hostStatus(res)); ok {
+					st = mapped
+				}
+				if guess, _, ok := r.extra.hostOS(res); ok && (hostOS.Fingerprint == "" || guess.Weight > hostOS.Weight) {
+					hostOS = guess
+				}
+				if m, ok := r.extra.hostMAC(res); ok && r.opts.reconcileByMAC {
+					mac = m
+				}
+				if t, ok := r.extra.hostTimestamp(res); ok {
+					notes = setLastSeen(notes, t)
+				}
+				for _, column := range r.opts.noteFieldFlags {
+					for _, v := range r.extra.hostColumn(res, column) {
+						notes = addNoteValue(notes, "recon-ng "+column, v)
+					}
+				}
+				if r.opts.groupTag {
+					for _, w := range r.extra.hostWorkspaces(res) {
+						tags = appendTag(tags, "workspace:"+w)
+					}
+				}
+			}
+			if r.opts.ignoreCaseTags {
+				tags = foldTags(tags, nil)
+			}
+			r.project.Hosts = append(r.project.Hosts, lair.Host{
+				IPv4:          ip,
+				MAC:           mac,
+				Hostnames:     resultHostnames(results),
+				OS:            hostOS,
+				Notes:         notes,
+				Tags:          tags,
+				Services:      r.servicesByIP[ip],
+				Status:        st.Status,
+				StatusMessage: st.Message,
+			})
+		}
+	}
+
+	if r.opts.defaultOS != "" {
+		for i, h := range r.project.Hosts {
+			if h.OS.Fingerprint == "" {
+				r.project.Hosts[i].OS = lair.OS{Tool: tool, Fingerprint: r.opts.defaultOS}
+			}
+		}
+	}
+
+	if r.opts.dateNotes {
+		for i, h := range r.project.Hosts {
+			if _, ok := r.notFound[h.IPv4]; r.updated[h.IPv4] || ok {
+				r.project.Hosts[i].Notes = setNote(h.Notes, "recon-ng collection date", r.collectedAt)
+			}
+		}
+	}
+}
+
+// addNetblocks adds the recon-ng netblocks to the project.
+func (r *reconciler) addNetblocks() {
+	existingCIDRs := map[string]bool{}
+	if r.opts.skipExistingNetblocks {
+		for _, n := range r.exproject.Netblocks {
+			existingCIDRs[normalizeCIDR(n.CIDR)] = true
+		}
+	}
+	skippedNetblocks := 0
+	for _, p := range r.data.NetBlocks {
+		if r.opts.skipExistingNetblocks {
+			cidr := normalizeCIDR(p.Netblock)
+			if existingCIDRs[cidr] {
+				skippedNetblocks++
+				continue
+			}
+			existingCIDRs[cidr] = true
+		}
+		nb := lair.Netblock{}
+		nb.ProjectID = r.project.ID
+		nb.MiscEmails = p.Email
+		nb.CIDR = p.Netblock
+		nb.Handle = p.OrgHandle
+		nb.Description = strings.Join(r.netblockTags, ", ")
+		r.project.Netblocks = append(r.project.Netblocks, nb)
+	}
+	if skippedNetblocks > 0 {
+		log.Printf("Info: Skipped %d netblocks that already exist in the project\n", skippedNetblocks)
+	}
+	if r.opts.dedupNetblocksByOverlap {
+		r.project.Netblocks = dedupNetblocks(r.exproject.Netblocks, r.project.Netblocks)
+	}
+
+	if r.opts.linkNetblocks {
+		// Lair hosts have no reference to a netblock, so the containing
+		// netblock is recorded in a note.
+		cidrs := []string{}
+		for _, n := range r.exproject.Netblocks {
+			cidrs = append(cidrs, n.CIDR)
+		}
+		for _, n := range r.project.Netblocks {
+			cidrs = append(cidrs, n.CIDR)
+		}
+		for i, h := range r.project.Hosts {
+			if cidr, ok := containingNetblock(h.IPv4, cidrs); ok {
+				r.project.Hosts[i].Notes = setNote(h.Notes, "recon-ng netblock", cidr)
+			}
+		}
+	}
+}
+
+// addPeople adds the recon-ng contacts to the project as people.
+func (r *reconciler) addPeople() {
+	invalidEmails := 0
+	for _, c := range r.data.Contacts {
+		email := c.Email
+		if r.opts.normalizeEmails {
+			var ok bool
+			if email, ok = normalizeEmail(email); !ok {
+				invalidEmails++
+			}
+		}
+		per := lair.Person{}
+		per.ProjectID = r.exproject.ID
+		per.PrincipalName = email
+		per.FirstName = c.FirstName
+		per.MiddleName = c.MiddleName
+		per.LastName = c.LastName
+		if email != "" {
+			per.Emails = append(per.Emails, email)
+		}
+		per.Address = c.Region
+		per.Department = c.Title
+		if phone := r.extra.phone(c); phone != "" {
+			per.Phones = append(per.Phones, phone)
+		}
+		per.Groups = append(per.Groups, r.peopleTags...)
+		per.Groups = append(per.Groups, "role:"+r.opts.contactRole)
+		if r.opts.tagFromEmailDomain {
+			if normalized, ok := normalizeEmail(email); ok && normalized != "" {
+				per.Groups = appendTag(per.Groups, "org:"+normalized[strings.LastIndex(normalized, "@")+1:])
+			}
+		}
+		if r.opts.anonymize {
+			anonymizePerson(&per, r.opts.anonymizeKey)
+		}
+		r.project.People = append(r.project.People, per)
+	}
+	if invalidEmails > 0 {
+		log.Printf("Info: Dropped %d invalid contact emails\n", invalidEmails)
+	}
+
+	if r.opts.netblockContacts {
+		known := map[string]bool{}
+		for _, c := range r.data.Contacts {
+			if email, ok := normalizeEmail(c.Email); ok {
+				known[email] = true
+			}
+		}
+		registrants := map[string]int{}
+		for _, n := range r.data.NetBlocks {
+			email, ok := normalizeEmail(n.Email)
+			if !ok || known[email] {
+				continue
+			}
+			if i, ok := registrants[email]; ok {
+				if !strings.Contains(r.project.People[i].Description, n.Netblock) {
+					r.project.People[i].Description += ", " + n.Netblock
+				}
+				continue
+			}
+			registrants[email] = len(r.project.People)
+			r.project.People = append(r.project.People, lair.Person{
+				ProjectID:     r.exproject.ID,
+				PrincipalName: email,
+				DisplayName:   n.OrgHandle,
+				Emails:        []string{email},
+				Description:   "Registrant contact for netblocks " + n.Netblock,
+				Groups:        append([]string{}, r.peopleTags...),
+			})
+		}
+		if r.opts.anonymize {
+			for _, i := range registrants {
+				anonymizePerson(&r.project.People[i], r.opts.anonymizeKey)
+			}
+		}
+		if len(registrants) > 0 {
+			log.Printf("Info: Imported %d netblock registrant contacts as people\n", len(registrants))
+		}
+	}
+
+	if r.opts.mergeContacts {
+		// Anonymized people are matched on their placeholder emails, which
+		// are the same across runs.
+		var merged int
+		r.project.People, merged = mergeContacts(r.exproject.People, r.project.People)
+		if merged > 0 {
+			log.Printf("Info: Merged %d contacts into existing people\n", merged)
+		}
+	}
+}
+
+// addCredentials adds the recon-ng credentials to the project, along with the
+// rows of unknown tables with -passthrough-unknown.
+func (r *reconciler) addCredentials() {
+	for _, cred := range r.data.Credentials {
+		lc := lair.Credential{}
+		lc.ProjectID = r.exproject.ID
+		lc.Username = cred.Username
+		lc.Hash = cred.Hash
+		lc.Password = cred.Password
+		lc.Service = cred.Leak
+		r.project.Credentials = append(r.project.Credentials, lc)
+	}
+
+	if r.opts.passthroughUnknown {
+		notes, tables := r.extra.passthroughNotes()
+		if len(tables) > 0 {
+			log.Printf("Info: Passing through %d rows from tables %s as project notes\n", len(notes), strings.Join(tables, ", "))
+			r.project.Notes = append(r.project.Notes, notes...)
+		}
+	}
+}
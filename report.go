@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	lair "github.com/lair-framework/go-lair"
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+// importReport holds the statistics for an import. It is the data passed to
+// the -report-template template.
+type importReport struct {
+	ProjectID    string
+	Files        []string
+	Hosts        int
+	UpdatedHosts int
+	ForcedHosts  int
+	Services     int
+	Netblocks    int
+	People       int
+	Credentials  int
+	Forced       bool
+	NotFound     []notFoundHost
+	// ServerMessages holds any details the API server returned about data
+	// it did not accept.
+	ServerMessages []string
+}
+
+// notFoundHost is a recon-ng host that did not exist in lair.
+type notFoundHost struct {
+	IP        string
+	Hostnames []string
+}
+
+// newImportReport builds the report for project. updated is the set of
+// existing lair hosts that were modified and forced reports whether the hosts
+// in notFound were imported.
+func newImportReport(project *lair.Project, files []string, updated map[string]bool, notFound map[string][]reconng.Host, forced bool) *importReport {
+	r := &importReport{
+		ProjectID:    project.ID,
+		Files:        files,
+		Hosts:        len(project.Hosts),
+		UpdatedHosts: len(updated),
+		Netblocks:    len(project.Netblocks),
+		People:       len(project.People),
+		Credentials:  len(project.Credentials),
+		Forced:       forced,
+	}
+	for _, h := range project.Hosts {
+		r.Services += len(h.Services)
+	}
+	if forced {
+		r.ForcedHosts = len(notFound)
+	}
+	for ip, results := range notFound {
+		r.NotFound = append(r.NotFound, notFoundHost{IP: ip, Hostnames: resultHostnames(results)})
+	}
+	sort.Slice(r.NotFound, func(i, j int) bool { return r.NotFound[i].IP < r.NotFound[j].IP })
+	return r
+}
+
+// loadReportTemplate parses the text/template in filename.
+func loadReportTemplate(filename string) (*template.Template, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(filename).Parse(string(buf))
+}
+
+// render writes the report to stdout using tmpl.
+func (r *importReport) render(tmpl *template.Template) error {
+	return tmpl.Execute(os.Stdout, r)
+}
+
+// pretty writes the report to w as aligned tables of the imported counts and
+// the hosts that were not found in lair.
+func (r *importReport) pretty(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "CLASS\tIMPORTED\tUPDATED\tNEW\n")
+	fmt.Fprintf(tw, "hosts\t%d\t%d\t%d\n", r.Hosts, r.UpdatedHosts, r.ForcedHosts)
+	fmt.Fprintf(tw, "services\t%d\t-\t-\n", r.Services)
+	fmt.Fprintf(tw, "netblocks\t%d\t-\t-\n", r.Netblocks)
+	fmt.Fprintf(tw, "people\t%d\t-\t-\n", r.People)
+	fmt.Fprintf(tw, "credentials\t%d\t-\t-\n", r.Credentials)
+	if len(r.NotFound) > 0 {
+		status := "not in lair"
+		if r.Forced {
+			status = "forced"
+		}
+		fmt.Fprintf(tw, "\nHOST\tHOSTNAMES\tSTATUS\n")
+		for _, h := range r.NotFound {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", h.IP, strings.Join(h.Hostnames, ","), status)
+		}
+	}
+	return tw.Flush()
+}
+
+// noteContent returns the report as plain text for a lair note, followed by
+// the hostname conflicts found during reconciliation.
+func (r *importReport) noteContent(conflicts []string) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Files: %s\n", strings.Join(r.Files, ", "))
+	fmt.Fprintf(b, "Hosts: %d (%d updated, %d new)\n", r.Hosts, r.UpdatedHosts, r.ForcedHosts)
+	fmt.Fprintf(b, "Services: %d\n", r.Services)
+	fmt.Fprintf(b, "Netblocks: %d\n", r.Netblocks)
+	fmt.Fprintf(b, "People: %d\n", r.People)
+	fmt.Fprintf(b, "Credentials: %d\n", r.Credentials)
+	if len(r.NotFound) > 0 && !r.Forced {
+		fmt.Fprintf(b, "\nNot found in lair:\n")
+		for _, h := range r.NotFound {
+			fmt.Fprintf(b, "%s %s\n", h.IP, strings.Join(h.Hostnames, ","))
+		}
+	}
+	if len(conflicts) > 0 {
+		fmt.Fprintf(b, "\nConflicts:\n")
+		for _, c := range conflicts {
+			fmt.Fprintf(b, "%s\n", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// schemaVersions are the recon-ng export schemas accepted by -expect-schema.
+var schemaVersions = map[string]bool{
+	"v4": true,
+	"v5": true,
+}
+
+// detectSchema guesses the recon-ng schema version of buf. Recon-ng 5 added a
+// notes column to every table, including those of workspaces upgraded from
+// 4.x, while both versions record the module of every row. It returns an
+// empty version when buf has no rows to tell from.
+func detectSchema(buf []byte) (string, error) {
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return "", err
+	}
+	version := ""
+	for table := range knownTables {
+		rows := []map[string]json.RawMessage{}
+		if err := json.Unmarshal(doc[table], &rows); err != nil {
+			continue
+		}
+		for _, row := range rows {
+			if _, ok := row["notes"]; ok {
+				return "v5", nil
+			}
+			version = "v4"
+		}
+	}
+	return version, nil
+}
+
+// checkSchema returns an error when buf does not look like an export of the
+// given schema version. An empty version accepts any export. The columns the
+// drone imports are named the same in both versions, so the version does not
+// change how buf is decoded.
+func checkSchema(buf []byte, version string) error {
+	if version == "" {
+		return nil
+	}
+	detected, err := detectSchema(buf)
+	if err != nil {
+		return err
+	}
+	if detected != "" && detected != version {
+		return fmt.Errorf("export looks like a recon-ng %s export but -expect-schema is %s", detected, version)
+	}
+	return nil
+}
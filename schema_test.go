@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+// v4Export and v5Export hold the same rows as reporting/json writes them with
+// the recon-ng 4.x and 5.x table columns.
+const (
+	v4Export = `{
+		"hosts": [{"host": "www.example.com", "ip_address": "93.184.216.34", "region": null, "country": null, "latitude": null, "longitude": null, "module": "recon/domains-hosts/hackertarget"}],
+		"contacts": [{"first_name": "Jane", "middle_name": null, "last_name": "Doe", "email": "jane@example.com", "title": "Engineer", "region": null, "country": null, "module": "recon/domains-contacts/whois_pocs"}]
+	}`
+	v5Export = `{
+		"hosts": [{"host": "www.example.com", "ip_address": "93.184.216.34", "region": null, "country": null, "latitude": null, "longitude": null, "notes": null, "module": "recon/domains-hosts/hackertarget"}],
+		"contacts": [{"first_name": "Jane", "middle_name": null, "last_name": "Doe", "email": "jane@example.com", "title": "Engineer", "region": null, "country": null, "notes": null, "module": "recon/domains-contacts/whois_pocs"}]
+	}`
+	emptyExport = `{"hosts": [], "contacts": []}`
+)
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name, buf, version, detected string
+		wantErr                      bool
+	}{
+		{"v4 unpinned", v4Export, "", "v4", false},
+		{"v5 unpinned", v5Export, "", "v5", false},
+		{"v4 pinned as v4", v4Export, "v4", "v4", false},
+		{"v5 pinned as v5", v5Export, "v5", "v5", false},
+		{"v4 pinned as v5", v4Export, "v5", "v4", true},
+		{"v5 pinned as v4", v5Export, "v4", "v5", true},
+		{"empty pinned as v4", emptyExport, "v4", "", false},
+	}
+	for _, tt := range tests {
+		if detected, err := detectSchema([]byte(tt.buf)); err != nil || detected != tt.detected {
+			t.Errorf("%s: detectSchema = %q, %v, want %q", tt.name, detected, err, tt.detected)
+		}
+		if err := checkSchema([]byte(tt.buf), tt.version); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkSchema = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSchemaVersionsDecodeAlike(t *testing.T) {
+	v4 := writeInput(t, "v4.json", v4Export)
+	v5 := writeInput(t, "v5.json", v5Export)
+	data4, _, err := parseFiles([]string{v4}, parseOptions{expectSchema: "v4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data5, _, err := parseFiles([]string{v5}, parseOptions{expectSchema: "v5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data4.Hosts) != 1 || data4.Hosts[0] != data5.Hosts[0] || len(data4.Contacts) != 1 || data4.Contacts[0] != data5.Contacts[0] {
+		t.Errorf("v4 export decoded to %+v, v5 export to %+v, want the same rows", data4, data5)
+	}
+}
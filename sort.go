@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	lair "github.com/lair-framework/go-lair"
+)
+
+// sortProject orders the records in project so that the same input always
+// produces the same payload: hosts by IPv4 address with their hostnames
+// sorted, netblocks by CIDR and people by email.
+func sortProject(project *lair.Project) {
+	for _, h := range project.Hosts {
+		sort.Strings(h.Hostnames)
+	}
+	sort.SliceStable(project.Hosts, func(i, j int) bool {
+		return lessIP(project.Hosts[i].IPv4, project.Hosts[j].IPv4)
+	})
+	sort.SliceStable(project.Netblocks, func(i, j int) bool {
+		a, b := normalizeCIDR(project.Netblocks[i].CIDR), normalizeCIDR(project.Netblocks[j].CIDR)
+		ipa, ipb := strings.SplitN(a, "/", 2)[0], strings.SplitN(b, "/", 2)[0]
+		if ipa != ipb {
+			return lessIP(ipa, ipb)
+		}
+		return a < b
+	})
+	sort.SliceStable(project.People, func(i, j int) bool {
+		a, b := project.People[i], project.People[j]
+		if a.PrincipalName != b.PrincipalName {
+			return a.PrincipalName < b.PrincipalName
+		}
+		if a.LastName != b.LastName {
+			return a.LastName < b.LastName
+		}
+		return a.FirstName < b.FirstName
+	})
+}
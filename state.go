@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// runState is persisted to the -state-file after every successful run so
+// that the next run only imports newer recon-ng data.
+type runState struct {
+	// LastSeen is the most recent discovery time seen in any import.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// loadState reads the run state from filename.
+func loadState(filename string) (runState, error) {
+	state := runState{}
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(buf, &state)
+	return state, err
+}
+
+// saveState writes state to filename.
+func saveState(filename string, state runState) error {
+	buf, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, buf, 0600)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Lair host statuses.
+const (
+	statusGrey   = "lair-grey"
+	statusBlue   = "lair-blue"
+	statusGreen  = "lair-green"
+	statusOrange = "lair-orange"
+	statusRed    = "lair-red"
+)
+
+// hostStatus is the lair status and status message a recon-ng status maps to.
+type hostStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// statusMap translates recon-ng host status values, compared
+// case-insensitively, to lair statuses.
+type statusMap map[string]hostStatus
+
+// defaultStatusMap is used when -map-status is not given.
+var defaultStatusMap = statusMap{
+	"new":         {Status: statusGrey},
+	"up":          {Status: statusGrey},
+	"in progress": {Status: statusBlue},
+	"testing":     {Status: statusBlue},
+	"clean":       {Status: statusGreen},
+	"done":        {Status: statusGreen},
+	"interesting": {Status: statusOrange},
+	"vulnerable":  {Status: statusOrange},
+	"compromised": {Status: statusRed},
+	"owned":       {Status: statusRed},
+}
+
+// loadStatusMap reads a statusMap from a JSON file of the form
+// {"<recon-ng status>": {"status": "<lair status>", "message": "<message>"}}.
+func loadStatusMap(filename string) (statusMap, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	raw := statusMap{}
+	if err := json.Unmarshal(buf, &raw); err != nil {
+		return nil, err
+	}
+	m := statusMap{}
+	for k, v := range raw {
+		switch v.Status {
+		case statusGrey, statusBlue, statusGreen, statusOrange, statusRed:
+		default:
+			return nil, fmt.Errorf("unknown lair status %q for %q", v.Status, k)
+		}
+		m[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	return m, nil
+}
+
+// lookup returns the lair status for a recon-ng status.
+func (m statusMap) lookup(status string) (hostStatus, bool) {
+	s, ok := m[strings.ToLower(strings.TrimSpace(status))]
+	return s, ok
+}
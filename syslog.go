@@ -0,0 +1,24 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+)
+
+// dialSyslog connects to the syslog server at addr, which is of the form
+// [network://]host:port with the network defaulting to udp. An empty addr
+// connects to the local syslog daemon.
+func dialSyslog(addr string) (io.Writer, error) {
+	network := ""
+	if addr != "" {
+		network = "udp"
+		if i := strings.Index(addr, "://"); i != -1 {
+			network, addr = addr[:i], addr[i+3:]
+		}
+	}
+	return syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_USER, "drone-recon-ng")
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// dialSyslog is not supported on this platform.
+func dialSyslog(addr string) (io.Writer, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
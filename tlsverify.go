@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strings"
+)
+
+// skipVerifyDialer dials TLS connections that skip certificate verification
+// for one host and verify all other hosts as usual. The host is matched
+// against the address being dialed because the server name in the TLS
+// connection state is empty for IP addresses.
+type skipVerifyDialer struct {
+	host   string
+	config *tls.Config
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// newSkipVerifyDialer returns a dialer for use as http.Transport.DialTLSContext
+// that skips verification for host. dial is the underlying dialer, nil uses a
+// net.Dialer.
+func newSkipVerifyDialer(host string, config *tls.Config, dial func(ctx context.Context, network, addr string) (net.Conn, error)) *skipVerifyDialer {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return &skipVerifyDialer{
+		host:   strings.ToLower(strings.Trim(strings.TrimSpace(host), "[]")),
+		config: config,
+		dial:   dial,
+	}
+}
+
+// DialTLSContext dials addr and performs the TLS handshake.
+func (d *skipVerifyDialer) DialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	config := d.config.Clone()
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	// The default verification is disabled so that VerifyConnection decides
+	// for every connection.
+	config.InsecureSkipVerify = true
+	config.VerifyConnection = verifyConnection(strings.EqualFold(host, d.host), config.ServerName, config.RootCAs)
+	tc := tls.Client(conn, config)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// verifyConnection returns a tls.Config.VerifyConnection callback that
+// accepts any certificate when skip is true and otherwise verifies the
+// certificates against roots, or the system roots when roots is nil, the
+// same way crypto/tls does by default.
+func verifyConnection(skip bool, name string, roots *x509.CertPool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if skip {
+			return nil
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			DNSName:       name,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := cs.PeerCertificates[0].Verify(opts)
+		return err
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTunnel forwards connections to the lair API server through an SSH
+// bastion.
+type sshTunnel struct {
+	client *ssh.Client
+}
+
+// dialSSHTunnel connects to the bastion given as user@host[:port] using the
+// private key in keyFile. The bastion's host key is checked against
+// knownHostsFile, or ~/.ssh/known_hosts when it is empty.
+func dialSSHTunnel(target, keyFile, knownHostsFile string) (*sshTunnel, error) {
+	at := strings.LastIndex(target, "@")
+	if at <= 0 || at == len(target)-1 {
+		return nil, errors.New("SSH tunnel must be in the form user@host[:port]")
+	}
+	user, addr := target[:at], target[at+1:]
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	if keyFile == "" {
+		return nil, errors.New("missing -ssh-key")
+	}
+	pem, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(pem)
+	if err != nil {
+		return nil, err
+	}
+
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sshTunnel{client: client}, nil
+}
+
+// DialContext opens a forwarded connection to addr from the bastion. It is
+// used as the DialContext of the API client's HTTP transport.
+func (t *sshTunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return t.client.Dial(network, addr)
+}
+
+// Close shuts down the SSH connection.
+func (t *sshTunnel) Close() error {
+	return t.client.Close()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	lair "github.com/lair-framework/go-lair"
+	reconng "github.com/lair-framework/go-recon-ng"
+)
+
+// validateData returns a message for every recon-ng record that is missing
+// the fields required to import it.
+func validateData(data *reconng.Data) []string {
+	violations := []string{}
+	for i, h := range data.Hosts {
+		if h.IPAddress == "" && h.Name == "" {
+			violations = append(violations, fmt.Sprintf("recon-ng host %d has neither an ip_address nor a host", i))
+		}
+	}
+	for i, n := range data.NetBlocks {
+		if n.Netblock == "" {
+			violations = append(violations, fmt.Sprintf("recon-ng netblock %d has no netblock", i))
+		}
+	}
+	for i, c := range data.Contacts {
+		if c.Email == "" && c.FirstName == "" && c.LastName == "" {
+			violations = append(violations, fmt.Sprintf("recon-ng contact %d has neither an email nor a name", i))
+		}
+	}
+	for i, c := range data.Credentials {
+		if c.Username == "" && c.Hash == "" {
+			violations = append(violations, fmt.Sprintf("recon-ng credential %d has neither a username nor a hash", i))
+		}
+	}
+	return violations
+}
+
+// validateProject returns a message for every record in the assembled lair
+// project that is missing required fields or has malformed values.
+func validateProject(project *lair.Project) []string {
+	violations := []string{}
+	for i, h := range project.Hosts {
+		switch {
+		case h.IPv4 == "" && len(h.Hostnames) == 0:
+			violations = append(violations, fmt.Sprintf("host %d has neither an IPv4 address nor a hostname", i))
+		case h.IPv4 != "" && net.ParseIP(h.IPv4) == nil:
+			violations = append(violations, fmt.Sprintf("host %d has an invalid IPv4 address %q", i, h.IPv4))
+		}
+	}
+	for i, n := range project.Netblocks {
+		if _, _, err := net.ParseCIDR(n.CIDR); err != nil {
+			violations = append(violations, fmt.Sprintf("netblock %d has an invalid CIDR %q", i, n.CIDR))
+		}
+	}
+	for i, p := range project.People {
+		if p.PrincipalName == "" && p.FirstName == "" && p.LastName == "" {
+			violations = append(violations, fmt.Sprintf("person %d has neither a principal name nor a name", i))
+		}
+	}
+	for i, c := range project.Credentials {
+		if c.Username == "" && c.Hash == "" {
+			violations = append(violations, fmt.Sprintf("credential %d has neither a username nor a hash", i))
+		}
+	}
+	return violations
+}
+
+// excessivePorts returns the IPs of hosts in project with more than threshold
+// services. The API server drops the services of such hosts unless ports are
+// forced.
+func excessivePorts(project *lair.Project, threshold int) []string {
+	ips := []string{}
+	for _, h := range project.Hosts {
+		if len(h.Services) > threshold {
+			ips = append(ips, h.IPv4)
+		}
+	}
+	return ips
+}
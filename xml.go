@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+)
+
+// xmlExport is the layout of a recon-ng XML export. The root element holds
+// one element per table, each table holds one element per row and each row
+// holds one element per column, mirroring the JSON export. The workspace name
+// is a root element holding only text.
+type xmlExport struct {
+	Tables []xmlTable `xml:",any"`
+}
+
+type xmlTable struct {
+	XMLName xml.Name
+	Rows    []xmlRow `xml:",any"`
+	Value   string   `xml:",chardata"`
+}
+
+type xmlRow struct {
+	Columns []xmlColumn `xml:",any"`
+}
+
+type xmlColumn struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// xmlToJSON converts a recon-ng XML export into the equivalent JSON export so
+// that it can be handled by reconng.Parse.
+func xmlToJSON(buf []byte) ([]byte, error) {
+	export := xmlExport{}
+	if err := xml.Unmarshal(buf, &export); err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	for _, table := range export.Tables {
+		if value := strings.TrimSpace(table.Value); len(table.Rows) == 0 && value != "" {
+			doc[table.XMLName.Local] = value
+			continue
+		}
+		rows, _ := doc[table.XMLName.Local].([]map[string]string)
+		for _, r := range table.Rows {
+			row := map[string]string{}
+			for _, c := range r.Columns {
+				row[c.XMLName.Local] = strings.TrimSpace(c.Value)
+			}
+			rows = append(rows, row)
+		}
+		doc[table.XMLName.Local] = rows
+	}
+	return json.Marshal(doc)
+}
+
+// detectFormat returns the format of filename based on its extension.
+func detectFormat(filename string) string {
+	if strings.EqualFold(filepath.Ext(filename), ".xml") {
+		return "xml"
+	}
+	return "json"
+}